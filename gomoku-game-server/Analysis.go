@@ -0,0 +1,198 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// BLUNDER_THRESHOLD is how much worse than the engine's choice a move has to be to get flagged
+const BLUNDER_THRESHOLD = SCORE_OPEN_THREE
+
+// FORCED_WIN_SCORE is the lowest score of a win the engine can force within its search depth
+const FORCED_WIN_SCORE = SCORE_WIN - 64
+
+// Engine-assisted play detection. Only moves where the player had a real choice count:
+// not the opening, and not forced moves (completing or blocking five).
+const (
+	SUSPICION_OPENING_MOVES = 6  // move numbers up to this are ignored
+	SUSPICION_TOP_MOVES     = 3  // a move agrees with the engine if it is among its top candidates
+	SUSPICION_MIN_MOVES     = 10 // fewer counted moves than this give no score
+)
+
+type PlayerAnalysis struct {
+	mBlunders   []uint32 // move numbers
+	mMissedWins []uint32 // move numbers where a forced win was on the board but not played
+
+	mCountedMoves int             // moves that count toward the suspicion score
+	mTopMatches   int             // counted moves that were among the engine's top candidates
+	mThinkTimes   []time.Duration // of counted moves
+}
+
+type GameAnalysis struct {
+	mPlayers  [3]PlayerAnalysis // indexed by StoneType
+	mAnalyzed int               // number of moves analyzed before the budget ran out
+	mTotal    int
+	mElapsed  time.Duration
+}
+
+func AnalysisEngineConfig(rule RuleVariant) EngineConfig {
+	return EngineConfig{mName: "analysis", mMaxDepth: 4, mWidth: 10, mTimeBudget: time.Second, mRule: rule}
+}
+
+// SuspicionEngineConfig searches every move to the same depth, however long it takes,
+// so that the suspicion score doesn't depend on the analysis budget or the machine
+func SuspicionEngineConfig(rule RuleVariant) EngineConfig {
+	return EngineConfig{mName: "suspicion", mMaxDepth: 2, mWidth: 10, mTimeBudget: time.Minute, mRule: rule}
+}
+
+func newAnalysisBoard(boardSize int) [][]byte {
+	board := make([][]byte, boardSize)
+	for i := range board {
+		board[i] = make([]byte, boardSize)
+	}
+	return board
+}
+
+// AnalyzeGame replays the moves and compares each of them with the engine's choice.
+// The blunder analysis stops at budget and moves not reached by then are left out of it.
+// The suspicion score always covers the whole game. See CountSuspicion.
+func AnalyzeGame(moves []MoveRecord, boardSize int, rule RuleVariant, budget time.Duration) GameAnalysis {
+	analysis := GameAnalysis{mTotal: len(moves)}
+	start := time.Now()
+	analysis.CountSuspicion(moves, boardSize, rule)
+
+	deadline := time.Now().Add(budget)
+	engine := NewEngine(AnalysisEngineConfig(rule), 0)
+	board := newAnalysisBoard(boardSize)
+
+	for i, m := range moves {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		moveBudget := remaining / time.Duration(len(moves)-i)
+
+		best := engine.SearchWithBudget(board, m.mStone, moveBudget/2)
+
+		if best.mDepth > 0 && (best.mXpos != m.mXpos || best.mYpos != m.mYpos) {
+			played, ok := engine.ScoreMoveAt(board, m.mStone, m.mXpos, m.mYpos, best.mDepth, moveBudget/2)
+
+			if ok && best.mScore >= FORCED_WIN_SCORE && played < FORCED_WIN_SCORE {
+				analysis.mPlayers[m.mStone].mMissedWins = append(analysis.mPlayers[m.mStone].mMissedWins, m.mMoveNumber)
+			} else if ok && best.mScore-played >= BLUNDER_THRESHOLD {
+				analysis.mPlayers[m.mStone].mBlunders = append(analysis.mPlayers[m.mStone].mBlunders, m.mMoveNumber)
+			}
+		}
+
+		board[m.mXpos][m.mYpos] = byte(m.mStone)
+		analysis.mAnalyzed++
+	}
+
+	analysis.mElapsed = time.Since(start)
+	return analysis
+}
+
+// CountSuspicion compares each move where the player had a real choice with the top candidates
+// of a fixed depth search, and keeps its think time
+func (ga *GameAnalysis) CountSuspicion(moves []MoveRecord, boardSize int, rule RuleVariant) {
+	engine := NewEngine(SuspicionEngineConfig(rule), 0)
+	board := newAnalysisBoard(boardSize)
+
+	for _, m := range moves {
+		if m.mMoveNumber > SUSPICION_OPENING_MOVES {
+			best := engine.Search(board, m.mStone)
+
+			if best.mDepth > 0 && len(best.mRootMoves) > 1 {
+				pa := &ga.mPlayers[m.mStone]
+				pa.mCountedMoves++
+				pa.mThinkTimes = append(pa.mThinkTimes, m.mThinkTime)
+
+				for j := 0; j < len(best.mRootMoves) && j < SUSPICION_TOP_MOVES; j++ {
+					if best.mRootMoves[j].mXpos == m.mXpos && best.mRootMoves[j].mYpos == m.mYpos {
+						pa.mTopMatches++
+						break
+					}
+				}
+			}
+		}
+
+		board[m.mXpos][m.mYpos] = byte(m.mStone)
+	}
+}
+
+func moveNumbersJson(numbers []uint32) string {
+	var items []string
+	for _, n := range numbers {
+		items = append(items, fmt.Sprint(n))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
+
+func (pa *PlayerAnalysis) EngineAgreement() float64 {
+	if pa.mCountedMoves == 0 {
+		return 0
+	}
+	return float64(pa.mTopMatches) / float64(pa.mCountedMoves)
+}
+
+// ThinkTimeVariation is the coefficient of variation (stddev / mean) of the think times.
+// People spend very uneven time on their moves, someone relaying engine moves doesn't.
+func (pa *PlayerAnalysis) ThinkTimeVariation() float64 {
+	if len(pa.mThinkTimes) < 2 {
+		return 0
+	}
+
+	var sum, sumSq float64
+	for _, t := range pa.mThinkTimes {
+		sum += t.Seconds()
+		sumSq += t.Seconds() * t.Seconds()
+	}
+
+	n := float64(len(pa.mThinkTimes))
+	mean := sum / n
+	if mean <= 0 {
+		return 0
+	}
+	return math.Sqrt(math.Max(sumSq/n-mean*mean, 0)) / mean
+}
+
+// SuspicionScore is 0 to 100. 70% comes from engine agreement, 30% from think time consistency.
+// Returns -1 when the player made too few counted moves to tell.
+func (pa *PlayerAnalysis) SuspicionScore() int {
+	if pa.mCountedMoves < SUSPICION_MIN_MOVES {
+		return -1
+	}
+
+	consistency := math.Max(0, 1-pa.ThinkTimeVariation())
+	return int(math.Round(100 * (0.7*pa.EngineAgreement() + 0.3*consistency)))
+}
+
+// MakeAnalysisJsonString is the compact summary attached to a player's game result
+func (ga *GameAnalysis) MakeAnalysisJsonString(st StoneType) string {
+	pa := &ga.mPlayers[st]
+
+	return fmt.Sprintf("{ \"Blunders\" : %s, \"MissedWins\" : %s, \"AnalyzedMoves\" : %d, \"TotalMoves\" : %d, "+
+		"\"EngineAgreement\" : %.2f, \"ThinkTimeVariation\" : %.2f, \"SuspicionScore\" : %d }",
+		moveNumbersJson(pa.mBlunders), moveNumbersJson(pa.mMissedWins), ga.mAnalyzed, ga.mTotal,
+		pa.EngineAgreement(), pa.ThinkTimeVariation(), pa.SuspicionScore())
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testSelfPlay records a game of the engine against itself, with think times that vary from move to move
+func testSelfPlay(boardSize int, moveCount int) []MoveRecord {
+	engine := NewEngine(EngineConfig{mName: "test", mMaxDepth: 2, mWidth: 8, mTimeBudget: time.Minute, mMaxNodes: 2000}, 0)
+	board := newAnalysisBoard(boardSize)
+
+	var moves []MoveRecord
+	st := STONE_BLACK
+	for i := 1; i <= moveCount; i++ {
+		result := engine.Search(board, st)
+		if result.mXpos < 0 || engine.isFive(result.mXpos, result.mYpos, st) {
+			break
+		}
+
+		moves = append(moves, MoveRecord{mMoveNumber: uint32(i), mXpos: result.mXpos, mYpos: result.mYpos, mStone: st, mThinkTime: time.Duration(i%7+1) * time.Second})
+		board[result.mXpos][result.mYpos] = byte(st)
+		st = opponentOf(st)
+	}
+	return moves
+}
+
+// The suspicion score covers the whole game, whatever the budget of the blunder analysis
+func TestSuspicionScoreIgnoresBudget(t *testing.T) {
+	moves := testSelfPlay(15, 40)
+	if len(moves) <= SUSPICION_OPENING_MOVES+2*SUSPICION_MIN_MOVES {
+		t.Fatalf("self-play game ended after %d moves", len(moves))
+	}
+
+	disabled := AnalyzeGame(moves, 15, RULE_FREESTYLE, 0)
+	budgeted := AnalyzeGame(moves, 15, RULE_FREESTYLE, 100*time.Millisecond)
+
+	if disabled.mAnalyzed != 0 {
+		t.Errorf("%d moves analyzed for blunders without a budget", disabled.mAnalyzed)
+	}
+	for _, st := range []StoneType{STONE_BLACK, STONE_WHITE} {
+		if disabled.mPlayers[st].SuspicionScore() < 0 {
+			t.Errorf("no suspicion score for %d without a budget", st)
+		}
+		if !reflect.DeepEqual(disabled.mPlayers[st].mThinkTimes, budgeted.mPlayers[st].mThinkTimes) ||
+			disabled.mPlayers[st].mTopMatches != budgeted.mPlayers[st].mTopMatches {
+			t.Errorf("suspicion of %d differs with the budget: %+v, %+v", st, disabled.mPlayers[st], budgeted.mPlayers[st])
+		}
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/gamelift"
+)
+
+const (
+	AUTH_TOKEN_REFRESH_MARGIN    = 5 * time.Minute // reconnect with a new token this long before the current one expires
+	SDK_RECONNECT_RETRY_INTERVAL = 5 * time.Second
+)
+
+// AnywhereAuth provides the compute auth token InitSDK needs to connect to GameLift directly from an
+// Anywhere compute, without the GameLift agent. A token given by flag or environment variable is used as is.
+// Otherwise the token is fetched with GetComputeAuthToken, and fetched again before it expires.
+type AnywhereAuth struct {
+	mFleetId    string
+	mHostId     string
+	mClient     *gamelift.Client // nil with a given token
+	mToken      string
+	mExpiration time.Time // zero if unknown
+}
+
+func (a *AnywhereAuth) Refreshable() bool {
+	return a.mClient != nil && !a.mExpiration.IsZero()
+}
+
+func (a *AnywhereAuth) Fetch(ctx context.Context) error {
+	output, err := a.mClient.GetComputeAuthToken(ctx,
+		&gamelift.GetComputeAuthTokenInput{
+			ComputeName: aws.String(a.mHostId),
+			FleetId:     aws.String(a.mFleetId),
+		})
+	if err != nil {
+		return err
+	}
+	if output.AuthToken == nil {
+		return errors.New("GetComputeAuthToken returned no auth token")
+	}
+
+	a.mToken = *output.AuthToken
+	a.mExpiration = time.Time{}
+	if output.ExpirationTimestamp != nil {
+		a.mExpiration = *output.ExpirationTimestamp
+	}
+	return nil
+}
+
+// RefreshAt is when to reconnect with a new token. Short-lived tokens are refreshed halfway.
+func (a *AnywhereAuth) RefreshAt() time.Time {
+	margin := AUTH_TOKEN_REFRESH_MARGIN
+	if lifetime := time.Until(a.mExpiration); margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+	return a.mExpiration.Add(-margin)
+}
+
+// KeepSDKConnected reconnects the SDK when a call finds the connection lost, and with a new compute auth token
+// before the current one expires. GameLift checks the token when the SDK connects and drops the connection when it expires.
+func (g *GameLiftManager) KeepSDKConnected() {
+	auth := g.mAnywhereAuth
+
+	for {
+		var timer *time.Timer
+		var refresh <-chan time.Time
+		if auth != nil && auth.Refreshable() {
+			timer = time.NewTimer(time.Until(auth.RefreshAt()))
+			refresh = timer.C
+		} else if g.mBackend.Disconnected() == nil {
+			return
+		}
+
+		var reason string
+		select {
+		case <-refresh:
+			reason = "The compute auth token expires at " + auth.mExpiration.String()
+		case err := <-g.mBackend.Disconnected():
+			reason = "Lost the connection to GameLift : " + err.Error()
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+
+		for {
+			err := g.ReconnectSDK(reason)
+			if err == nil {
+				break
+			}
+
+			myLogger.Print("Reconnecting to GameLift failed : ", err.Error())
+			time.Sleep(SDK_RECONNECT_RETRY_INTERVAL)
+		}
+
+		/// Calls that failed before the new connection was made don't need another one
+		select {
+		case <-g.mBackend.Disconnected():
+		default:
+		}
+	}
+}
+
+// ReconnectSDK replaces the SDK connection with a new one, made with a new auth token if it can be refreshed.
+// The process registers again with the same process ID, so a running game session carries on.
+// The game goes on meanwhile. SDK calls it makes before the new connection is up fail like any other SDK error.
+func (g *GameLiftManager) ReconnectSDK(reason string) error {
+	myLogger.Print("Reconnecting to GameLift. ", reason)
+
+	g.mSDKLock.Lock()
+	defer g.mSDKLock.Unlock()
+
+	/// Shutdown ended the connection for good
+	if g.IsShuttingDown() {
+		return nil
+	}
+
+	/// A network call, so not under the game lock. Only this goroutine changes the token after InitializeGameLift
+	if g.mAnywhereAuth != nil && g.mAnywhereAuth.Refreshable() {
+		if err := g.mAnywhereAuth.Fetch(context.TODO()); err != nil {
+			return err
+		}
+		myLogger.Print("Compute auth token expires at ", g.mAnywhereAuth.mExpiration)
+	}
+
+	g.mLock.Lock()
+	if g.mAnywhereAuth != nil {
+		g.mServerParameters.AuthToken = g.mAnywhereAuth.mToken
+	}
+	serverParameters := g.mServerParameters
+	processParameters := g.mProcessParameters
+	g.mLock.Unlock()
+
+	/// Network calls as well. ProcessReady may bring SDK callbacks, which take the game lock
+	if err := g.mBackend.Destroy(); err != nil {
+		myLogger.Print("Destroy failed : ", err.Error())
+	}
+
+	if err := g.mBackend.InitSDK(serverParameters); err != nil {
+		return err
+	}
+	return g.mBackend.ProcessReady(processParameters)
+}
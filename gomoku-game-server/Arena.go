@@ -0,0 +1,321 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// engineConfigList collects repeated -engine flags
+type engineConfigList []EngineConfig
+
+func (l *engineConfigList) String() string {
+	var names []string
+	for _, c := range *l {
+		names = append(names, c.mName)
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses "name=strong,level=4,depth=6,width=10,budget=200ms,noise=0,book=true,nodes=50000".
+// level picks the preset, the other keys override it.
+func (l *engineConfigList) Set(value string) error {
+	kv := map[string]string{}
+	for _, field := range strings.Split(value, ",") {
+		parts := strings.SplitN(field, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected key=value: %q", field)
+		}
+		kv[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	level := 3
+	if v, ok := kv["level"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("level: %w", err)
+		}
+		level = n
+	}
+	config := EngineConfigForLevel(level)
+
+	for k, v := range kv {
+		var err error
+		switch k {
+		case "level":
+		case "name":
+			config.mName = v
+		case "depth":
+			config.mMaxDepth, err = strconv.Atoi(v)
+		case "width":
+			config.mWidth, err = strconv.Atoi(v)
+		case "budget":
+			config.mTimeBudget, err = time.ParseDuration(v)
+		case "noise":
+			config.mNoise, err = strconv.Atoi(v)
+		case "book":
+			config.mUseBook, err = strconv.ParseBool(v)
+		case "nodes":
+			config.mMaxNodes, err = strconv.Atoi(v)
+		default:
+			err = fmt.Errorf("unknown key")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+
+	*l = append(*l, config)
+	return nil
+}
+
+type ArenaGame struct {
+	mIndex int
+	mBlack int // index into the engine list
+	mWhite int
+	mSeed  int64
+}
+
+type ArenaStats struct {
+	mGames  int
+	mWins   int
+	mLosses int
+	mDraws  int
+	mScore  [][]float64 // mScore[i][j] points engine i scored against engine j
+	mPlayed [][]int
+}
+
+// PlayArenaGame plays one bot-versus-bot game through GameSession, without network or GameLift.
+// Returns the final game status or an error if the rules rejected a move or the game panicked.
+func PlayArenaGame(black EngineConfig, white EngineConfig, seed int64) (status GameStatus, moves uint32, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	g := &GameLiftManager{}
+	gs := &GameSession{
+		mPlayerBlack: nil,
+		mPlayerWhite: nil,
+		mGameStatus:  GS_NOT_STARTED,
+		mCurrentTurn: STONE_NONE,
+		mConfig:      DefaultSessionConfig(),
+
+		mGameLiftManager: g,
+	}
+	g.mGameSession = gs
+
+	black.mRule, white.mRule = gs.mConfig.mRule, gs.mConfig.mRule
+	blackBot := &BotPlayer{mPlayerName: black.mName, mEngine: NewEngine(black, seed), mGameSession: gs}
+	whiteBot := &BotPlayer{mPlayerName: white.mName, mEngine: NewEngine(white, seed+1), mGameSession: gs}
+
+	gs.PlayerEnter(blackBot)
+	gs.PlayerEnter(whiteBot)
+	gs.BroadcastGameStart()
+
+	for gs.mGameStatus == GS_STARTED {
+		bot := blackBot
+		if gs.mCurrentTurn == STONE_WHITE {
+			bot = whiteBot
+		}
+
+		result := bot.NextMove()
+		if ec := gs.PutStone(bot, result.mXpos, result.mYpos); ec != EC_NONE {
+			return gs.mGameStatus, gs.mMoveNumber, fmt.Errorf("move %d %s by %s rejected with error code %d", gs.mMoveNumber+1, psnCoord(result.mXpos, result.mYpos), bot.mPlayerName, ec)
+		}
+	}
+
+	return gs.mGameStatus, gs.mMoveNumber, nil
+}
+
+// EstimateElo fits ratings to the pairwise scores, anchored to a mean of 0.
+// Every pair gets one virtual draw so that a perfect score doesn't run off to infinity.
+func EstimateElo(score [][]float64, played [][]int) []float64 {
+	n := len(score)
+	ratings := make([]float64, n)
+
+	for iter := 0; iter < 1000; iter++ {
+		for i := 0; i < n; i++ {
+			var actual, expected, games float64
+			for j := 0; j < n; j++ {
+				if i == j || played[i][j] == 0 {
+					continue
+				}
+				g := float64(played[i][j]) + 1
+				actual += score[i][j] + 0.5
+				expected += g / (1 + math.Pow(10, (ratings[j]-ratings[i])/400))
+				games += g
+			}
+			if games > 0 {
+				ratings[i] += 400 * (actual - expected) / games
+			}
+		}
+
+		var mean float64
+		for _, r := range ratings {
+			mean += r
+		}
+		for i := range ratings {
+			ratings[i] -= mean / float64(n)
+		}
+	}
+
+	return ratings
+}
+
+// RunArena implements the arena subcommand:
+//
+//	gomoku-in-go arena -engine name=a,level=3 -engine name=b,level=4 [-games N] [-parallel N] [-seed N]
+//
+// Every pair of engines plays -games games with alternating colors. Returns the process exit code,
+// non-zero when any game hit a rules error, so it doubles as a soak test.
+func RunArena(args []string) int {
+	var engines engineConfigList
+	var games, parallel int
+	var seed int64
+	var verbose bool
+
+	fs := flag.NewFlagSet("arena", flag.ExitOnError)
+	fs.Var(&engines, "engine", "engine configuration, repeatable. e.g. name=strong,level=4,depth=6,width=10,budget=200ms,noise=0,book=true,nodes=50000")
+	fs.IntVar(&games, "games", 100, "games per pair of engines")
+	fs.IntVar(&parallel, "parallel", 4, "games played at the same time")
+	fs.Int64Var(&seed, "seed", 1, "base random seed. Use with nodes= limits for reproducible runs")
+	fs.BoolVar(&verbose, "v", false, "print game server logs to stderr")
+	fs.Parse(args)
+
+	if len(engines) < 2 {
+		fmt.Fprintln(os.Stderr, "arena needs at least two -engine configurations")
+		return 2
+	}
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	if verbose {
+		myLogger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		myLogger = log.New(io.Discard, "", 0)
+	}
+
+	var schedule []ArenaGame
+	for i := 0; i < len(engines); i++ {
+		for j := i + 1; j < len(engines); j++ {
+			for k := 0; k < games; k++ {
+				game := ArenaGame{mIndex: len(schedule), mBlack: i, mWhite: j}
+				if k%2 == 1 {
+					game.mBlack, game.mWhite = j, i
+				}
+				game.mSeed = seed + int64(game.mIndex)*2
+				schedule = append(schedule, game)
+			}
+		}
+	}
+
+	stats := make([]ArenaStats, len(engines))
+	score := make([][]float64, len(engines))
+	played := make([][]int, len(engines))
+	for i := range engines {
+		score[i] = make([]float64, len(engines))
+		played[i] = make([]int, len(engines))
+	}
+
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+	var failures int
+	var totalMoves uint32
+	jobs := make(chan ArenaGame)
+	start := time.Now()
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for game := range jobs {
+				status, moves, err := PlayArenaGame(engines[game.mBlack], engines[game.mWhite], game.mSeed)
+
+				lock.Lock()
+				totalMoves += moves
+				if err != nil {
+					failures++
+					fmt.Fprintf(os.Stderr, "game %d (%s vs %s, seed %d): %s\n", game.mIndex, engines[game.mBlack].mName, engines[game.mWhite].mName, game.mSeed, err)
+					lock.Unlock()
+					continue
+				}
+
+				b, w := game.mBlack, game.mWhite
+				stats[b].mGames++
+				stats[w].mGames++
+				played[b][w]++
+				played[w][b]++
+
+				switch status {
+				case GS_GAME_OVER_BLACK_WIN:
+					stats[b].mWins++
+					stats[w].mLosses++
+					score[b][w]++
+				case GS_GAME_OVER_WHITE_WIN:
+					stats[w].mWins++
+					stats[b].mLosses++
+					score[w][b]++
+				default:
+					stats[b].mDraws++
+					stats[w].mDraws++
+					score[b][w] += 0.5
+					score[w][b] += 0.5
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+
+	for _, game := range schedule {
+		jobs <- game
+	}
+	close(jobs)
+	wg.Wait()
+
+	elo := EstimateElo(score, played)
+
+	fmt.Printf("%d games, %d moves in %s\n", len(schedule), totalMoves, time.Since(start).Round(time.Millisecond))
+	fmt.Printf("%-16s %6s %6s %6s %6s %8s %8s\n", "engine", "games", "wins", "losses", "draws", "win%", "elo")
+	for i, c := range engines {
+		s := stats[i]
+		var winRate float64
+		if s.mGames > 0 {
+			winRate = 100 * (float64(s.mWins) + 0.5*float64(s.mDraws)) / float64(s.mGames)
+		}
+		fmt.Printf("%-16s %6d %6d %6d %6d %7.1f%% %+8.0f\n", c.mName, s.mGames, s.mWins, s.mLosses, s.mDraws, winRate, elo[i])
+	}
+
+	if failures > 0 {
+		fmt.Printf("%d games failed\n", failures)
+		return 1
+	}
+	return 0
+}
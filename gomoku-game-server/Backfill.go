@@ -0,0 +1,186 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"aws/amazon-gamelift-go-sdk/model"
+	"aws/amazon-gamelift-go-sdk/model/request"
+	"time"
+)
+
+const AUTO_BACKFILL_MODE = "AUTOMATIC" // FlexMatch starts backfill by itself
+
+// StartBackfill asks FlexMatch for a replacement of a matched player who left before the game started.
+// The remaining players go into the request with their teams and attributes, so the new player
+// is matched against them.
+func (g *GameLiftManager) StartBackfill(playerId string) {
+	g.mBackfillLock.Lock()
+
+	match := g.mMatchData
+	match.RemovePlayer(playerId)
+
+	/// the players changed, so a running ticket is out of date
+	if g.mBackfillTicketId != "" {
+		g.StopBackfillTicket()
+	}
+
+	if match.AutoBackfillMode == AUTO_BACKFILL_MODE {
+		myLogger.Print("[BACKFILL] Automatic backfill. Waiting for FlexMatch to replace ", playerId)
+	} else {
+		backfillRequest := request.NewStartMatchBackfill()
+		backfillRequest.GameSessionArn = g.mGameSessionId
+		backfillRequest.MatchmakingConfigurationArn = match.MatchmakingConfigurationArn
+		backfillRequest.Players = match.BackfillPlayers()
+
+		ticketId, err := g.mBackend.StartMatchBackfill(backfillRequest)
+		if err != nil {
+			g.mBackfillLock.Unlock()
+			myLogger.Print("[BACKFILL] StartMatchBackfill Fail: ", err.Error())
+			g.EndUnfilledGameSession("StartMatchBackfill failed")
+			return
+		}
+
+		g.mBackfillTicketId = ticketId
+		myLogger.Printf("[BACKFILL] Looking for a replacement of %s with ticket %s\n", playerId, ticketId)
+	}
+
+	if g.mBackfillTimer != nil {
+		g.mBackfillTimer.Stop()
+	}
+	g.mBackfilling = true
+	g.mBackfillTimer = time.AfterFunc(g.mBackfillTimeout, g.BackfillTimedOut)
+
+	g.mBackfillLock.Unlock()
+}
+
+// StopBackfill is called when the game starts or the game session ends
+func (g *GameLiftManager) StopBackfill() {
+	g.mBackfillLock.Lock()
+	defer g.mBackfillLock.Unlock()
+
+	if !g.mBackfilling {
+		return
+	}
+
+	g.mBackfillTimer.Stop()
+	g.mBackfilling = false
+	if g.mBackfillTicketId != "" {
+		g.StopBackfillTicket()
+	}
+}
+
+// must hold mBackfillLock
+func (g *GameLiftManager) StopBackfillTicket() {
+	stopRequest := request.NewStopMatchBackfill()
+	stopRequest.GameSessionArn = g.mGameSessionId
+	stopRequest.MatchmakingConfigurationArn = g.mMatchData.MatchmakingConfigurationArn
+	stopRequest.TicketID = g.mBackfillTicketId
+
+	if err := g.mBackend.StopMatchBackfill(stopRequest); err != nil {
+		myLogger.Print("[BACKFILL] StopMatchBackfill Fail: ", err.Error())
+	} else {
+		myLogger.Print("[BACKFILL] Stopped ticket ", g.mBackfillTicketId)
+	}
+
+	/// the BACKFILL_CANCELLED update for this ticket is ignored from now on
+	g.mBackfillTicketId = ""
+}
+
+func (g *GameLiftManager) BackfillTimedOut() {
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	g.mBackfillLock.Lock()
+	backfilling := g.mBackfilling
+	g.mBackfillLock.Unlock()
+
+	if !backfilling {
+		return
+	}
+
+	myLogger.Printf("[BACKFILL] No replacement player after %s\n", g.mBackfillTimeout)
+	g.StopBackfill()
+	g.EndUnfilledGameSession("backfill timed out")
+}
+
+// MatchmakingDataUpdated takes the match with the replacement player. The new player is accepted
+// when connecting like the others.
+func (g *GameLiftManager) MatchmakingDataUpdated(gameSession model.GameSession, ticketId string) {
+	match, err := ParseMatchmakerData(gameSession.MatchmakerData)
+	if err != nil || match == nil {
+		myLogger.Print("[BACKFILL] Invalid MatchmakerData: ", err)
+		return
+	}
+
+	g.mBackfillLock.Lock()
+	defer g.mBackfillLock.Unlock()
+
+	for _, team := range match.Teams {
+		for _, player := range team.Players {
+			if g.mMatchData == nil {
+				continue
+			}
+			if p, _ := g.mMatchData.FindPlayer(player.PlayerId); p == nil {
+				myLogger.Printf("[BACKFILL] Expecting %s on team %s\n", player.PlayerId, team.Name)
+			}
+		}
+	}
+	g.mMatchData = match
+
+	if g.mBackfilling && (g.mBackfillTicketId == "" || g.mBackfillTicketId == ticketId) {
+		g.mBackfillTimer.Stop()
+		g.mBackfilling = false
+		g.mBackfillTicketId = ""
+	}
+}
+
+// BackfillEnded handles BACKFILL_FAILED, BACKFILL_TIMED_OUT and BACKFILL_CANCELLED
+func (g *GameLiftManager) BackfillEnded(ticketId string, reason model.UpdateReason) {
+	g.mBackfillLock.Lock()
+	current := g.mBackfilling && (g.mBackfillTicketId == "" || g.mBackfillTicketId == ticketId)
+	if current {
+		g.mBackfillTimer.Stop()
+		g.mBackfilling = false
+		g.mBackfillTicketId = ""
+	}
+	g.mBackfillLock.Unlock()
+
+	if !current {
+		myLogger.Printf("[BACKFILL] Ignoring %s of ticket %s\n", reason, ticketId)
+		return
+	}
+
+	g.EndUnfilledGameSession(string(reason))
+}
+
+// EndUnfilledGameSession gives up on a game that can't start because a player is missing
+func (g *GameLiftManager) EndUnfilledGameSession(reason string) {
+	gs := g.mGameSession
+	if gs == nil || gs.mGameStatus != GS_NOT_STARTED {
+		return
+	}
+
+	myLogger.Printf("[BACKFILL] Ending game session without a replacement player: %s\n", reason)
+
+	if ps, ok := gs.mPlayerBlack.(*PlayerSession); ok {
+		ps.SendError(EC_MATCH_CANCELLED, PKT_CS_START, 0)
+	}
+
+	g.EndGameSession()
+}
@@ -0,0 +1,141 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BotPlayer is a server-side opponent. It sits at the board like a PlayerSession
+// and answers its turn with an Engine search.
+type BotPlayer struct {
+	mPlayerName  string
+	mEngine      *Engine
+	mGameSession *GameSession
+	mAutoPlay    bool  // move on its own when notified of its turn. Arena drives the bots itself
+	mThinking    int32 // 1 while a search is running
+}
+
+func NewBotPlayer(level int, gs *GameSession, seed int64) *BotPlayer {
+	config := EngineConfigForLevel(level)
+	config.mRule = gs.mConfig.mRule
+
+	return &BotPlayer{
+		mPlayerName:  fmt.Sprintf("Bot-%s", config.mName),
+		mEngine:      NewEngine(config, seed),
+		mGameSession: gs,
+		mAutoPlay:    true,
+	}
+}
+
+func IsBot(p GamePlayer) bool {
+	_, ok := p.(*BotPlayer)
+	return ok
+}
+
+func (bp *BotPlayer) GetPlayerSessionId() string            { return "bot-" + bp.mPlayerName }
+func (bp *BotPlayer) GetPlayerName() string                 { return bp.mPlayerName }
+func (bp *BotPlayer) GetPlayerScore() int                   { return 0 }
+func (bp *BotPlayer) HasCapability(c ClientCapability) bool { return c == CAP_DELTA_BOARD }
+func (bp *BotPlayer) Disconnect(dr DisconnectReason)        {}
+
+// PostSend receives what GameSession sends to this seat. The bot only cares whether it's its turn now.
+func (bp *BotPlayer) PostSend(data []byte, len int) bool {
+	if len < 4 {
+		return true
+	}
+
+	switch PacketTypes(binary.LittleEndian.Uint16(data[2:4])) {
+	case PKT_SC_START, PKT_SC_BOARD_STATUS, PKT_SC_STONE_PLACED:
+		bp.CheckTurn()
+	}
+
+	return true
+}
+
+func (bp *BotPlayer) MyColor() StoneType {
+	if bp.mGameSession.mPlayerBlack == GamePlayer(bp) {
+		return STONE_BLACK
+	}
+	return STONE_WHITE
+}
+
+func (bp *BotPlayer) CheckTurn() {
+	gs := bp.mGameSession
+
+	if !bp.mAutoPlay || gs.mGameStatus != GS_STARTED || gs.mBoardStatus == nil || gs.mCurrentTurn != bp.MyColor() {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&bp.mThinking, 0, 1) {
+		return
+	}
+
+	// GameSession is sending to us from inside PutStone. Think outside of it.
+	go bp.Think(gs.mMoveNumber)
+}
+
+// NextMove searches the current board of the game session
+func (bp *BotPlayer) NextMove() SearchResult {
+	return bp.mEngine.Search(bp.CopyBoard(), bp.MyColor())
+}
+
+func (bp *BotPlayer) CopyBoard() [][]byte {
+	gs := bp.mGameSession
+
+	board := make([][]byte, len(gs.mBoardStatus))
+	for i := range gs.mBoardStatus {
+		board[i] = append([]byte(nil), gs.mBoardStatus[i]...)
+	}
+
+	return board
+}
+
+// Think searches on a copy of the board without holding the game lock, so that the game goes on meanwhile,
+// e.g. the opponent leaves or a clock runs out. The move is dropped if it did.
+func (bp *BotPlayer) Think(moveNumber uint32) {
+	defer atomic.StoreInt32(&bp.mThinking, 0)
+
+	gs := bp.mGameSession
+	g := gs.mGameLiftManager
+
+	g.mLock.Lock()
+	board, color := bp.CopyBoard(), bp.MyColor()
+	g.mLock.Unlock()
+
+	start := time.Now()
+	result := bp.mEngine.Search(board, color)
+
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	if gs.mMoveNumber != moveNumber || gs.mGameStatus != GS_STARTED {
+		return
+	}
+
+	myLogger.Printf("[BOT] %s plays %s (score %d, depth %d, book %t) in %s\n", bp.mPlayerName, psnCoord(result.mXpos, result.mYpos), result.mScore, result.mDepth, result.mFromBook, time.Since(start))
+
+	atomic.StoreInt32(&bp.mThinking, 0)
+	if ec := gs.PutStone(bp, result.mXpos, result.mYpos); ec != EC_NONE {
+		myLogger.Printf("[BOT] %s move rejected with error code %d\n", bp.mPlayerName, ec)
+	}
+}
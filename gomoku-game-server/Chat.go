@@ -0,0 +1,329 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const MAX_CHAT_LEN = 200 // bytes of UTF-8 text
+
+// Chat and emotes share one token bucket per player: CHAT_RATE_BURST messages at once,
+// then one more every CHAT_RATE_INTERVAL
+const CHAT_RATE_BURST = 5
+const CHAT_RATE_INTERVAL = 2 * time.Second
+
+type Emote uint16
+
+const (
+	EMOTE_HELLO    Emote = 0
+	EMOTE_GOOD     Emote = 1
+	EMOTE_THINKING Emote = 2
+	EMOTE_OOPS     Emote = 3
+	EMOTE_THANKS   Emote = 4
+	EMOTE_GG       Emote = 5
+
+	EMOTE_MAX Emote = 6
+)
+
+// MessageFilter decides what of a chat message reaches the other side.
+// It returns the text to relay, or false to drop the message.
+type MessageFilter interface {
+	Filter(message string) (string, bool)
+}
+
+// WordListFilter masks listed words (case-insensitive, whole words) with '*'
+type WordListFilter struct {
+	mPattern *regexp.Regexp // nil when the list is empty
+}
+
+func NewWordListFilter(words []string) *WordListFilter {
+	var quoted []string
+	for _, w := range words {
+		w = strings.TrimSpace(w)
+		if w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+
+	f := &WordListFilter{}
+	if len(quoted) > 0 {
+		/// longest first, so that a word isn't cut short by a listed prefix of it
+		sort.SliceStable(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+		f.mPattern = regexp.MustCompile(`(?i)(` + strings.Join(quoted, "|") + `)`)
+	}
+	return f
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r)
+}
+
+// LoadWordListFilter reads one word per line. Empty lines and lines starting with '#' are skipped.
+func LoadWordListFilter(path string) (*WordListFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			words = append(words, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewWordListFilter(words), nil
+}
+
+func (f *WordListFilter) Filter(message string) (string, bool) {
+	if f.mPattern == nil {
+		return message, true
+	}
+
+	/// \b of regexp only knows ASCII words, so whole words are checked here. Korean words wouldn't match otherwise
+	var sb strings.Builder
+	last := 0
+	for _, m := range f.mPattern.FindAllStringIndex(message, -1) {
+		before, _ := utf8.DecodeLastRuneInString(message[:m[0]])
+		after, _ := utf8.DecodeRuneInString(message[m[1]:])
+		if isWordRune(before) || isWordRune(after) {
+			continue
+		}
+		sb.WriteString(message[last:m[0]])
+		sb.WriteString(strings.Repeat("*", utf8.RuneCountInString(message[m[0]:m[1]])))
+		last = m[1]
+	}
+	sb.WriteString(message[last:])
+
+	return sb.String(), true
+}
+
+type ChatRateLimiter struct {
+	mTokens float64
+	mLast   time.Time
+}
+
+func (rl *ChatRateLimiter) Allow(now time.Time) bool {
+	if rl.mLast.IsZero() {
+		rl.mTokens = CHAT_RATE_BURST
+	} else {
+		rl.mTokens += float64(now.Sub(rl.mLast)) / float64(CHAT_RATE_INTERVAL)
+		if rl.mTokens > CHAT_RATE_BURST {
+			rl.mTokens = CHAT_RATE_BURST
+		}
+	}
+	rl.mLast = now
+
+	if rl.mTokens < 1 {
+		return false
+	}
+	rl.mTokens--
+	return true
+}
+
+// ChatState is kept per seat in GameSession
+type ChatState struct {
+	mLimiter       ChatRateLimiter
+	mMutedOpponent bool // don't relay the opponent's chat and emotes to this seat
+}
+
+// SanitizeChat drops control characters and invalid UTF-8
+func SanitizeChat(message string) string {
+	message = strings.ToValidUTF8(message, "")
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, message))
+}
+
+// CheckChatSender returns the seat of psess if it may chat now
+func (gs *GameSession) CheckChatSender(psess GamePlayer) (StoneType, ErrorCode) {
+	st := gs.SeatOf(psess)
+	if st == STONE_NONE {
+		return STONE_NONE, EC_NOT_SEATED
+	}
+
+	if gs.mGameStatus == GS_NOT_STARTED {
+		return STONE_NONE, EC_GAME_NOT_STARTED
+	}
+
+	if !gs.mChat[st].mLimiter.Allow(time.Now()) {
+		myLogger.Print("[CHAT] Rate limited: ", psess.GetPlayerSessionId())
+		return STONE_NONE, EC_RATE_LIMITED
+	}
+
+	return st, EC_NONE
+}
+
+func (gs *GameSession) SeatOf(psess GamePlayer) StoneType {
+	if psess == nil {
+		return STONE_NONE
+	}
+	if psess == gs.mPlayerBlack {
+		return STONE_BLACK
+	}
+	if psess == gs.mPlayerWhite {
+		return STONE_WHITE
+	}
+	return STONE_NONE
+}
+
+func (gs *GameSession) RelayChat(psess GamePlayer, message string) ErrorCode {
+	if len(message) > MAX_CHAT_LEN {
+		return EC_MESSAGE_TOO_LONG
+	}
+
+	st, ec := gs.CheckChatSender(psess)
+	if ec != EC_NONE {
+		return ec
+	}
+
+	message = SanitizeChat(message)
+	if message == "" {
+		return EC_NONE
+	}
+
+	relayed, ok := message, true
+	if filter := gs.mGameLiftManager.mChatFilter; filter != nil {
+		relayed, ok = filter.Filter(message)
+	}
+
+	// The original text goes to the log for abuse reports
+	myLogger.Printf("[CHAT] %s (%s): %q filtered=%t dropped=%t\n", psess.GetPlayerName(), psess.GetPlayerSessionId(), message, relayed != message, !ok)
+
+	if !ok {
+		return EC_MESSAGE_REJECTED
+	}
+	if len(relayed) > MAX_CHAT_LEN {
+		relayed = strings.ToValidUTF8(relayed[:MAX_CHAT_LEN], "")
+	}
+
+	gs.RelayToOpponentAndSpectators(st, gs.MakeChatPacket(st, psess.GetPlayerName(), relayed))
+	return EC_NONE
+}
+
+func (gs *GameSession) RelayEmote(psess GamePlayer, emote Emote) ErrorCode {
+	if emote >= EMOTE_MAX {
+		return EC_OUT_OF_RANGE
+	}
+
+	st, ec := gs.CheckChatSender(psess)
+	if ec != EC_NONE {
+		return ec
+	}
+
+	myLogger.Printf("[CHAT] %s (%s): emote %d\n", psess.GetPlayerName(), psess.GetPlayerSessionId(), emote)
+
+	gs.RelayToOpponentAndSpectators(st, gs.MakeEmotePacket(st, emote))
+	return EC_NONE
+}
+
+// SetMute mutes or unmutes the opponent of psess, for psess only
+func (gs *GameSession) SetMute(psess GamePlayer, muted bool) ErrorCode {
+	st := gs.SeatOf(psess)
+	if st == STONE_NONE {
+		return EC_NOT_SEATED
+	}
+
+	gs.mChat[st].mMutedOpponent = muted
+	myLogger.Printf("[CHAT] %s (%s): mute opponent %t\n", psess.GetPlayerName(), psess.GetPlayerSessionId(), muted)
+
+	return EC_NONE
+}
+
+func (gs *GameSession) RelayToOpponentAndSpectators(from StoneType, packet []byte) {
+	to := STONE_BLACK
+	if from == STONE_BLACK {
+		to = STONE_WHITE
+	}
+
+	opponent := gs.mPlayerWhite
+	if to == STONE_BLACK {
+		opponent = gs.mPlayerBlack
+	}
+
+	if opponent != nil && !gs.mChat[to].mMutedOpponent {
+		if false == opponent.PostSend(packet, len(packet)) {
+			opponent.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+
+	gs.PublishToSpectators(packet, false)
+}
+
+func (gs *GameSession) MakeChatPacket(from StoneType, name string, message string) []byte {
+	var size, ptype uint16
+
+	// Chat message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// StoneType (1byte) sender
+	// mSenderName (MAX_STRING_LEN byte)
+	// mMessage (up to MAX_CHAT_LEN byte) UTF-8, not terminated
+	var outPacket [2 + 2 + 1 + MAX_STRING_LEN + MAX_CHAT_LEN]byte
+
+	size = uint16(2 + 2 + 1 + MAX_STRING_LEN + len(message))
+	ptype = uint16(PKT_SC_CHAT)
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	outPacket[4] = byte(from)
+	copy(outPacket[5:5+MAX_STRING_LEN], name)
+	copy(outPacket[5+MAX_STRING_LEN:], message)
+
+	return outPacket[0:size]
+}
+
+func (gs *GameSession) MakeEmotePacket(from StoneType, emote Emote) []byte {
+	var size, ptype uint16
+
+	// Emote message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// StoneType (1byte) sender
+	// mEmote (2byte)
+	var outPacket [2 + 2 + 1 + 2]byte
+
+	size = 2 + 2 + 1 + 2
+	ptype = uint16(PKT_SC_EMOTE)
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	outPacket[4] = byte(from)
+	binary.LittleEndian.PutUint16(outPacket[5:], uint16(emote))
+
+	return outPacket[0:size]
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWordListFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		words   []string
+		message string
+		want    string
+	}{
+		{"no words", nil, "you noob", "you noob"},
+		{"blank words", []string{" ", ""}, "you noob", "you noob"},
+		{"whole word", []string{"noob"}, "you noob", "you ****"},
+		{"case-insensitive", []string{"noob"}, "NooB!", "****!"},
+		{"not inside a word", []string{"noob"}, "noobish", "noobish"},
+		{"several words", []string{"bad", "worse"}, "bad and worse", "*** and *****"},
+		{"regexp characters are literal", []string{"a.b"}, "a.b axb", "*** axb"},
+		{"non-ASCII word", []string{"바보"}, "너 바보야 바보", "너 바보야 **"},
+		{"listed prefix", []string{"no", "noob"}, "no noob", "** ****"},
+		{"adjacent matches", []string{"bad"}, "bad bad", "*** ***"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := NewWordListFilter(tt.words).Filter(tt.message)
+			if !ok || got != tt.want {
+				t.Errorf("Filter(%q) = %q, %v, want %q, true", tt.message, got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadWordListFilter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("# comment\nnoob\n\n  idiot  \n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := LoadWordListFilter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := f.Filter("comment noob idiot"); got != "comment **** *****" {
+		t.Errorf("Filter = %q", got)
+	}
+
+	if _, err := LoadWordListFilter(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("no error for a missing file")
+	}
+}
+
+func TestChatRateLimiter(t *testing.T) {
+	tests := []struct {
+		name  string
+		times []time.Duration // of the messages, from the first
+		want  []bool
+	}{
+		{
+			name:  "burst",
+			times: []time.Duration{0, 0, 0, 0, 0, 0},
+			want:  []bool{true, true, true, true, true, false},
+		},
+		{
+			name:  "one more every interval after the burst",
+			times: []time.Duration{0, 0, 0, 0, 0, CHAT_RATE_INTERVAL / 2, CHAT_RATE_INTERVAL, CHAT_RATE_INTERVAL},
+			want:  []bool{true, true, true, true, true, false, true, false},
+		},
+		{
+			name:  "refills up to the burst",
+			times: []time.Duration{0, time.Hour, time.Hour, time.Hour, time.Hour, time.Hour, time.Hour},
+			want:  []bool{true, true, true, true, true, true, false},
+		},
+		{
+			name:  "steady rate",
+			times: []time.Duration{0, CHAT_RATE_INTERVAL, 2 * CHAT_RATE_INTERVAL, 3 * CHAT_RATE_INTERVAL, 4 * CHAT_RATE_INTERVAL, 5 * CHAT_RATE_INTERVAL, 6 * CHAT_RATE_INTERVAL},
+			want:  []bool{true, true, true, true, true, true, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rl ChatRateLimiter
+			start := time.Now()
+
+			for i, d := range tt.times {
+				if got := rl.Allow(start.Add(d)); got != tt.want[i] {
+					t.Errorf("message %d at %s allowed %v, want %v", i+1, d, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeChat(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"gg", "gg"},
+		{"  gg  ", "gg"},
+		{"g\x00g\n", "gg"},
+		{"\x1b[31mred", "[31mred"},
+		{"bad \xff utf-8", "bad  utf-8"},
+		{"\t\r\n", ""},
+		{"좋은 게임", "좋은 게임"},
+	}
+
+	for _, tt := range tests {
+		if got := SanitizeChat(tt.message); got != tt.want {
+			t.Errorf("SanitizeChat(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// ColorAssignment is how the two players are given black and white. Black moves first, which is a big advantage.
+type ColorAssignment byte
+
+const (
+	CA_CONNECTION_ORDER ColorAssignment = 0 // first connected player plays black
+	CA_RANDOM           ColorAssignment = 1 // coin flip with GameSession.mColorSeed
+	CA_TEAM             ColorAssignment = 2 // FlexMatch team "blue" plays black, "red" white
+	CA_RATING           ColorAssignment = 3 // lower rated player plays black
+	CA_ALTERNATE        ColorAssignment = 4 // swap colors every game of a series (game property "seriesGame")
+)
+
+const BLACK_TEAM = "blue"
+const WHITE_TEAM = "red"
+
+var colorAssignmentNames = []string{"connection", "random", "team", "rating", "alternate"}
+
+func (ca ColorAssignment) String() string {
+	if int(ca) < len(colorAssignmentNames) {
+		return colorAssignmentNames[ca]
+	}
+	return fmt.Sprintf("unknown(%d)", ca)
+}
+
+func ParseColorAssignment(name string) (ColorAssignment, error) {
+	for i, n := range colorAssignmentNames {
+		if strings.EqualFold(n, name) {
+			return ColorAssignment(i), nil
+		}
+	}
+	return CA_CONNECTION_ORDER, fmt.Errorf("unknown color assignment %q. One of %s", name, strings.Join(colorAssignmentNames, ", "))
+}
+
+// AssignColors is called once both seats are taken, with the first player in the black seat.
+// It swaps the seats if needed and keeps the method actually used in mColorAssignment,
+// which is random when the configured one can't tell the players apart.
+func (gs *GameSession) AssignColors() {
+	black, white := gs.mPlayerBlack, gs.mPlayerWhite
+	method := gs.mColorAssignment
+
+	switch method {
+	case CA_TEAM:
+		blackTeam, whiteTeam := TeamOf(black), TeamOf(white)
+		if blackTeam == WHITE_TEAM && whiteTeam == BLACK_TEAM {
+			black, white = white, black
+		} else if blackTeam != BLACK_TEAM || whiteTeam != WHITE_TEAM {
+			method = CA_RANDOM
+		}
+
+	case CA_RATING:
+		if black.GetPlayerScore() > white.GetPlayerScore() {
+			black, white = white, black
+		} else if black.GetPlayerScore() == white.GetPlayerScore() {
+			method = CA_RANDOM
+		}
+
+	case CA_ALTERNATE:
+		// Both games of a series must agree on who is "first", whatever the connection order:
+		// the blue team if there is one, the smaller player name otherwise
+		blackTeam := TeamOf(black)
+		whiteTeam := TeamOf(white)
+		if whiteTeam == BLACK_TEAM || (blackTeam != BLACK_TEAM && white.GetPlayerName() < black.GetPlayerName()) {
+			black, white = white, black
+		}
+		if gs.mConfig.mSeriesGame%2 == 0 {
+			black, white = white, black
+		}
+	}
+
+	if method == CA_RANDOM && rand.New(rand.NewSource(gs.mColorSeed)).Intn(2) == 1 {
+		black, white = white, black
+	}
+
+	gs.mPlayerBlack, gs.mPlayerWhite = black, white
+	gs.mColorAssignment = method
+
+	myLogger.Printf("[PlayerEnter] Colors by %s (seed %d, series game %d): black %s, white %s\n", method, gs.mColorSeed, gs.mConfig.mSeriesGame, black.GetPlayerSessionId(), white.GetPlayerSessionId())
+}
+
+// TeamOf returns the FlexMatch team of a player, or "" for bots and when the game session wasn't matchmade
+func TeamOf(p GamePlayer) string {
+	if ps, ok := p.(*PlayerSession); ok {
+		return ps.mTeam
+	}
+	return ""
+}
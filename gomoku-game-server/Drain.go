@@ -0,0 +1,87 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+const STATE_DRAINING = "DRAINING" // appended to the state in the state file while draining
+
+func (g *GameLiftManager) IsDraining() bool {
+	return atomic.LoadInt32(&g.mDraining) != 0
+}
+
+// Drain stops the process from taking new game sessions, e.g. before scale-in or a rollout.
+// A running game session is played to the end and the process ends then instead of getting ready
+// for the next one. An idle process ends right away.
+func (g *GameLiftManager) Drain(reason string) {
+	if g.IsShuttingDown() || !atomic.CompareAndSwapInt32(&g.mDraining, 0, 1) {
+		return
+	}
+
+	myLogger.Print("[DRAIN] Draining: ", reason)
+
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	if g.mGameSession == nil {
+		g.WriteStateFile("IDLE")
+		myLogger.Print("[DRAIN] No game session. Ending process")
+		g.TerminateGameSession(0)
+		return
+	}
+
+	g.WriteStateFile("ACTIVE")
+}
+
+// StartAdminServer serves local requests to the process. Keep addr on a loopback or private address.
+//
+//	POST /drain    start draining. See Drain
+//	GET  /healthz  health probes for container health checks. See CheckHealth
+func (g *GameLiftManager) StartAdminServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/drain", g.HandleDrain)
+	mux.HandleFunc("/healthz", g.HandleHealthz)
+
+	go func() {
+		myLogger.Print("[ADMIN] Listening on ", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			myLogger.Print("[ADMIN] HTTP server failed: ", err)
+		}
+	}()
+}
+
+func (g *GameLiftManager) HandleDrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to start draining", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if g.IsShuttingDown() {
+		http.Error(w, "shutting down", http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+
+	/// an idle process ends right away. Answer first
+	go g.Drain("admin request from " + r.RemoteAddr)
+}
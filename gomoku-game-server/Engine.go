@@ -0,0 +1,457 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const (
+	SCORE_FIVE       = 10000000
+	SCORE_OPEN_FOUR  = 100000
+	SCORE_FOUR       = 10000
+	SCORE_OPEN_THREE = 5000
+	SCORE_THREE      = 500
+	SCORE_OPEN_TWO   = 200
+	SCORE_TWO        = 30
+	SCORE_ONE        = 5
+
+	SCORE_WIN = 1000000000 // a won position. Reduced by the number of plies to get there
+)
+
+// STONE_OFF_BOARD is what the engine reads outside the board. It blocks lines like an opponent stone.
+const STONE_OFF_BOARD StoneType = 3
+
+var engineDirections = [4][2]int{{1, 0}, {0, 1}, {1, 1}, {1, -1}}
+
+type EngineConfig struct {
+	mName       string
+	mMaxDepth   int           // iterative deepening stops here
+	mWidth      int           // number of candidate moves searched at each node
+	mTimeBudget time.Duration // per move
+	mNoise      int           // random score added to root moves. Makes lower levels beatable
+	mUseBook    bool
+	mMaxNodes   int         // 0 for no limit. Unlike the time budget, a node limit makes searches reproducible
+	mRule       RuleVariant // of the game searched. With RULE_STANDARD an overline neither wins nor counts as a shape
+}
+
+const MIN_BOT_LEVEL = 1
+const MAX_BOT_LEVEL = 5
+
+func EngineConfigForLevel(level int) EngineConfig {
+	if level < MIN_BOT_LEVEL {
+		level = MIN_BOT_LEVEL
+	} else if level > MAX_BOT_LEVEL {
+		level = MAX_BOT_LEVEL
+	}
+
+	presets := [MAX_BOT_LEVEL + 1]EngineConfig{
+		{},
+		{mMaxDepth: 1, mWidth: 8, mTimeBudget: 100 * time.Millisecond, mNoise: SCORE_OPEN_THREE, mUseBook: false},
+		{mMaxDepth: 2, mWidth: 8, mTimeBudget: 200 * time.Millisecond, mNoise: SCORE_THREE, mUseBook: false},
+		{mMaxDepth: 4, mWidth: 10, mTimeBudget: 500 * time.Millisecond, mNoise: SCORE_TWO, mUseBook: true},
+		{mMaxDepth: 6, mWidth: 10, mTimeBudget: 1 * time.Second, mNoise: 0, mUseBook: true},
+		{mMaxDepth: 8, mWidth: 12, mTimeBudget: 2 * time.Second, mNoise: 0, mUseBook: true},
+	}
+
+	config := presets[level]
+	config.mName = "level" + string(rune('0'+level))
+	return config
+}
+
+type ScoredMove struct {
+	mXpos  int
+	mYpos  int
+	mScore int
+}
+
+type SearchResult struct {
+	ScoredMove
+	mDepth     int          // deepest fully searched iteration
+	mRootMoves []ScoredMove // root candidates of that iteration, best first
+	mFromBook  bool
+}
+
+// Engine searches gomoku positions with iterative deepening alpha-beta under a time budget.
+// Only the candidates closest to existing stones are searched, and forced replies
+// (completing or blocking five) cut the tree down to a single move.
+type Engine struct {
+	mConfig EngineConfig
+	mRand   *rand.Rand
+
+	mSize     int
+	mCells    []StoneType // flat board, index x*mSize+y
+	mDeadline time.Time
+	mNodes    int
+	mTimeout  bool
+}
+
+func NewEngine(config EngineConfig, seed int64) *Engine {
+	return &Engine{
+		mConfig: config,
+		mRand:   rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (e *Engine) load(board [][]byte) {
+	e.mSize = len(board)
+	e.mCells = make([]StoneType, e.mSize*e.mSize)
+	for x := 0; x < e.mSize; x++ {
+		for y := 0; y < e.mSize; y++ {
+			e.mCells[x*e.mSize+y] = StoneType(board[x][y])
+		}
+	}
+}
+
+func (e *Engine) at(x int, y int) StoneType {
+	if x < 0 || x >= e.mSize || y < 0 || y >= e.mSize {
+		return STONE_OFF_BOARD
+	}
+	return e.mCells[x*e.mSize+y]
+}
+
+func opponentOf(st StoneType) StoneType {
+	if st == STONE_BLACK {
+		return STONE_WHITE
+	}
+	return STONE_BLACK
+}
+
+// Search picks a move for toMove. board is indexed [x][y] like GameSession.mBoardStatus.
+func (e *Engine) Search(board [][]byte, toMove StoneType) SearchResult {
+	return e.SearchWithBudget(board, toMove, e.mConfig.mTimeBudget)
+}
+
+func (e *Engine) SearchWithBudget(board [][]byte, toMove StoneType, budget time.Duration) SearchResult {
+	e.load(board)
+
+	if e.mConfig.mUseBook {
+		if x, y, ok := e.bookMove(); ok {
+			return SearchResult{ScoredMove: ScoredMove{x, y, 0}, mFromBook: true}
+		}
+	}
+
+	stones := 0
+	for _, c := range e.mCells {
+		if c != STONE_NONE {
+			stones++
+		}
+	}
+	if stones == 0 {
+		return SearchResult{ScoredMove: ScoredMove{e.mSize / 2, e.mSize / 2, 0}}
+	}
+
+	e.mDeadline = time.Now().Add(budget)
+	e.mTimeout = false
+	e.mNodes = 0
+
+	var result SearchResult
+	rootMoves := e.candidates(toMove, e.mConfig.mWidth)
+	if len(rootMoves) == 0 {
+		result.mXpos, result.mYpos = -1, -1
+		return result
+	}
+
+	// Noise is applied once so that every iteration agrees on the bias
+	noise := make([]int, len(rootMoves))
+	for i := range noise {
+		if e.mConfig.mNoise > 0 {
+			noise[i] = e.mRand.Intn(e.mConfig.mNoise)
+		}
+	}
+
+	result.ScoredMove = rootMoves[0]
+	result.mRootMoves = rootMoves
+
+	for depth := 1; depth <= e.mConfig.mMaxDepth; depth++ {
+		scored := make([]ScoredMove, len(rootMoves))
+		alpha := -SCORE_WIN - 1
+
+		for i, m := range rootMoves {
+			score := e.scoreMove(m, toMove, depth, alpha)
+			if e.mTimeout {
+				break
+			}
+			scored[i] = ScoredMove{m.mXpos, m.mYpos, score + noise[i]}
+			if score > alpha {
+				alpha = score
+			}
+		}
+		if e.mTimeout {
+			break
+		}
+
+		sort.SliceStable(scored, func(i, j int) bool { return scored[i].mScore > scored[j].mScore })
+		result.ScoredMove = scored[0]
+		result.mRootMoves = scored
+		result.mDepth = depth
+
+		// keep the noise attached to its move for the next iteration
+		reordered := make([]int, len(scored))
+		for i, m := range scored {
+			for j, r := range rootMoves {
+				if r.mXpos == m.mXpos && r.mYpos == m.mYpos {
+					reordered[i] = noise[j]
+				}
+			}
+		}
+		for i := range scored {
+			rootMoves[i] = ScoredMove{scored[i].mXpos, scored[i].mYpos, 0}
+		}
+		noise = reordered
+
+		if scored[0].mScore >= SCORE_WIN-depth || len(rootMoves) == 1 {
+			break
+		}
+	}
+
+	return result
+}
+
+// ScoreMoveAt searches the reply to toMove playing (x, y) with a full window, so the score is exact
+// rather than a bound. Returns false if the budget ran out first.
+func (e *Engine) ScoreMoveAt(board [][]byte, toMove StoneType, x int, y int, depth int, budget time.Duration) (int, bool) {
+	e.load(board)
+	e.mDeadline = time.Now().Add(budget)
+	e.mTimeout = false
+	e.mNodes = 0
+
+	score := e.scoreMove(ScoredMove{x, y, 0}, toMove, depth, -SCORE_WIN-1)
+	return score, !e.mTimeout
+}
+
+func (e *Engine) scoreMove(m ScoredMove, toMove StoneType, depth int, alpha int) int {
+	e.mCells[m.mXpos*e.mSize+m.mYpos] = toMove
+	defer func() { e.mCells[m.mXpos*e.mSize+m.mYpos] = STONE_NONE }()
+
+	if e.isFive(m.mXpos, m.mYpos, toMove) {
+		return SCORE_WIN - 1
+	}
+	return -e.negamax(opponentOf(toMove), depth-1, 2, -SCORE_WIN-1, -alpha)
+}
+
+func (e *Engine) negamax(toMove StoneType, depth int, ply int, alpha int, beta int) int {
+	e.mNodes++
+	if e.mNodes&1023 == 0 && time.Now().After(e.mDeadline) {
+		e.mTimeout = true
+	}
+	if e.mConfig.mMaxNodes > 0 && e.mNodes >= e.mConfig.mMaxNodes {
+		e.mTimeout = true
+	}
+	if e.mTimeout {
+		return 0
+	}
+
+	if depth <= 0 {
+		return e.Evaluate(toMove)
+	}
+
+	moves := e.candidates(toMove, e.mConfig.mWidth)
+	if len(moves) == 0 {
+		return 0 // board full
+	}
+
+	best := -SCORE_WIN - 1
+	for _, m := range moves {
+		idx := m.mXpos*e.mSize + m.mYpos
+		e.mCells[idx] = toMove
+
+		var score int
+		if e.isFive(m.mXpos, m.mYpos, toMove) {
+			score = SCORE_WIN - ply
+		} else {
+			score = -e.negamax(opponentOf(toMove), depth-1, ply+1, -beta, -alpha)
+		}
+
+		e.mCells[idx] = STONE_NONE
+
+		if score > best {
+			best = score
+		}
+		if score > alpha {
+			alpha = score
+		}
+		if alpha >= beta || e.mTimeout {
+			break
+		}
+	}
+
+	return best
+}
+
+// lineRun counts st stones through (x, y) along (dx, dy), assuming (x, y) holds st, and how many ends are open.
+func (e *Engine) lineRun(x int, y int, dx int, dy int, st StoneType) (int, int) {
+	count, open := 1, 0
+
+	i := 1
+	for e.at(x+dx*i, y+dy*i) == st {
+		count++
+		i++
+	}
+	if e.at(x+dx*i, y+dy*i) == STONE_NONE {
+		open++
+	}
+
+	i = 1
+	for e.at(x-dx*i, y-dy*i) == st {
+		count++
+		i++
+	}
+	if e.at(x-dx*i, y-dy*i) == STONE_NONE {
+		open++
+	}
+
+	return count, open
+}
+
+func shapeScore(count int, open int) int {
+	if count >= 5 {
+		return SCORE_FIVE
+	}
+	if open == 0 {
+		return 0
+	}
+
+	switch count {
+	case 4:
+		if open == 2 {
+			return SCORE_OPEN_FOUR
+		}
+		return SCORE_FOUR
+	case 3:
+		if open == 2 {
+			return SCORE_OPEN_THREE
+		}
+		return SCORE_THREE
+	case 2:
+		if open == 2 {
+			return SCORE_OPEN_TWO
+		}
+		return SCORE_TWO
+	}
+	return SCORE_ONE * open
+}
+
+// runScore is shapeScore under the rule of the game
+func (e *Engine) runScore(count int, open int) int {
+	if count > 5 && e.mConfig.mRule == RULE_STANDARD {
+		return 0
+	}
+	return shapeScore(count, open)
+}
+
+func (e *Engine) isFive(x int, y int, st StoneType) bool {
+	for _, d := range engineDirections {
+		if count, _ := e.lineRun(x, y, d[0], d[1], st); count == 5 || (count > 5 && e.mConfig.mRule == RULE_FREESTYLE) {
+			return true
+		}
+	}
+	return false
+}
+
+// pointScore rates playing st at the empty (x, y) by the shapes it would make
+func (e *Engine) pointScore(x int, y int, st StoneType) int {
+	score := 0
+	for _, d := range engineDirections {
+		score += e.runScore(e.lineRun(x, y, d[0], d[1], st))
+	}
+	return score
+}
+
+// candidates returns up to width empty points near existing stones, most promising first.
+// If toMove can complete five, or has to stop the opponent's five, only those points are returned.
+func (e *Engine) candidates(toMove StoneType, width int) []ScoredMove {
+	var moves, wins, blocks []ScoredMove
+	opp := opponentOf(toMove)
+
+	for x := 0; x < e.mSize; x++ {
+		for y := 0; y < e.mSize; y++ {
+			if e.mCells[x*e.mSize+y] != STONE_NONE || !e.hasNeighbor(x, y) {
+				continue
+			}
+
+			attack := e.pointScore(x, y, toMove)
+			defense := e.pointScore(x, y, opp)
+			m := ScoredMove{x, y, attack + defense*9/10}
+
+			if attack >= SCORE_FIVE {
+				wins = append(wins, m)
+			} else if defense >= SCORE_FIVE {
+				blocks = append(blocks, m)
+			}
+			moves = append(moves, m)
+		}
+	}
+
+	if len(wins) > 0 {
+		return wins[:1]
+	}
+	if len(blocks) > 0 {
+		return blocks
+	}
+
+	sort.SliceStable(moves, func(i, j int) bool { return moves[i].mScore > moves[j].mScore })
+	if len(moves) > width {
+		moves = moves[:width]
+	}
+	return moves
+}
+
+func (e *Engine) hasNeighbor(x int, y int) bool {
+	for dx := -2; dx <= 2; dx++ {
+		for dy := -2; dy <= 2; dy++ {
+			if st := e.at(x+dx, y+dy); st == STONE_BLACK || st == STONE_WHITE {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Evaluate scores the loaded position from the point of view of toMove
+func (e *Engine) Evaluate(toMove StoneType) int {
+	var score [3]int
+
+	for x := 0; x < e.mSize; x++ {
+		for y := 0; y < e.mSize; y++ {
+			st := e.mCells[x*e.mSize+y]
+			if st == STONE_NONE {
+				continue
+			}
+			for _, d := range engineDirections {
+				// count every run once, from its first stone
+				if e.at(x-d[0], y-d[1]) == st {
+					continue
+				}
+				score[st] += e.runScore(e.lineRun(x, y, d[0], d[1], st))
+			}
+		}
+	}
+
+	// the side to move gets to use its threats first
+	return score[toMove]*11/10 - score[opponentOf(toMove)]
+}
+
+// EvaluateBoard loads board and returns the static evaluation for toMove
+func (e *Engine) EvaluateBoard(board [][]byte, toMove StoneType) int {
+	e.load(board)
+	return e.Evaluate(toMove)
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testBoard builds a board indexed [x][y] like GameSession.mBoardStatus, one row per y:
+// 'X' black, 'O' white, anything else empty
+func testBoard(rows ...string) [][]byte {
+	board := make([][]byte, len(rows))
+	for x := range board {
+		board[x] = make([]byte, len(rows))
+		for y, row := range rows {
+			switch row[x] {
+			case 'X':
+				board[x][y] = byte(STONE_BLACK)
+			case 'O':
+				board[x][y] = byte(STONE_WHITE)
+			}
+		}
+	}
+	return board
+}
+
+// testEngineConfig searches a fixed number of nodes, so results don't depend on the machine
+func testEngineConfig() EngineConfig {
+	return EngineConfig{mName: "test", mMaxDepth: 4, mWidth: 10, mTimeBudget: time.Minute, mMaxNodes: 20000}
+}
+
+func TestEngineSearch(t *testing.T) {
+	tests := []struct {
+		name   string
+		board  [][]byte
+		toMove StoneType
+		want   [][2]int // any of these
+	}{
+		{
+			name:   "empty board takes the center",
+			board:  testBoard(".........", ".........", ".........", ".........", ".........", ".........", ".........", ".........", "........."),
+			toMove: STONE_BLACK,
+			want:   [][2]int{{4, 4}},
+		},
+		{
+			name: "completes an open four",
+			board: testBoard(
+				".........",
+				".........",
+				"....O....",
+				"....O....",
+				"..XXXX...",
+				"....O....",
+				".........",
+				".........",
+				"........."),
+			toMove: STONE_BLACK,
+			want:   [][2]int{{1, 4}, {6, 4}},
+		},
+		{
+			name: "blocks a four",
+			board: testBoard(
+				"X.......X",
+				".........",
+				".........",
+				".........",
+				"OOOO.....",
+				".........",
+				".........",
+				".........",
+				"X...X...."),
+			toMove: STONE_BLACK,
+			want:   [][2]int{{4, 4}},
+		},
+		{
+			name: "wins before blocking",
+			board: testBoard(
+				"XXXX.....",
+				".........",
+				"OOOO.....",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				"........."),
+			toMove: STONE_BLACK,
+			want:   [][2]int{{4, 0}},
+		},
+		{
+			name: "white completes five too",
+			board: testBoard(
+				".........",
+				".O.......",
+				"..O......",
+				"...O.....",
+				"....O....",
+				".........",
+				"XX.X.X...",
+				".........",
+				"........."),
+			toMove: STONE_WHITE,
+			want:   [][2]int{{0, 0}, {5, 5}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := NewEngine(testEngineConfig(), 1).Search(tt.board, tt.toMove)
+
+			for _, w := range tt.want {
+				if result.mXpos == w[0] && result.mYpos == w[1] {
+					return
+				}
+			}
+			t.Errorf("Search played (%d, %d), want one of %v", result.mXpos, result.mYpos, tt.want)
+		})
+	}
+}
+
+func TestEngineOverline(t *testing.T) {
+	/// (3, 0) makes six in a row, (4, 2) makes exactly five
+	board := testBoard(
+		"XXX.XX...",
+		".........",
+		"XXXX.O...",
+		".........",
+		".........",
+		"O..O.....",
+		".........",
+		"O...O....",
+		".........")
+
+	tests := []struct {
+		rule     RuleVariant
+		overline bool     // whether six in a row wins
+		want     [][2]int // any of these
+	}{
+		{RULE_FREESTYLE, true, [][2]int{{3, 0}, {4, 2}}},
+		{RULE_STANDARD, false, [][2]int{{4, 2}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule.String(), func(t *testing.T) {
+			config := testEngineConfig()
+			config.mRule = tt.rule
+			engine := NewEngine(config, 1)
+
+			engine.load(board)
+			if five := engine.isFive(3, 0, STONE_BLACK); five != tt.overline {
+				t.Errorf("isFive on six in a row = %v, want %v", five, tt.overline)
+			}
+
+			result := engine.Search(board, STONE_BLACK)
+			for _, w := range tt.want {
+				if result.mXpos == w[0] && result.mYpos == w[1] {
+					return
+				}
+			}
+			t.Errorf("Search played (%d, %d), want one of %v", result.mXpos, result.mYpos, tt.want)
+		})
+	}
+}
+
+func TestEngineSearchFullBoard(t *testing.T) {
+	rows := make([]string, MIN_BOARD_SIZE)
+	for y := range rows {
+		/// two of a color at most in a row, column or diagonal
+		for x := 0; x < MIN_BOARD_SIZE; x++ {
+			if (x/2+y)%2 == 0 {
+				rows[y] += "X"
+			} else {
+				rows[y] += "O"
+			}
+		}
+	}
+
+	result := NewEngine(testEngineConfig(), 1).Search(testBoard(rows...), STONE_BLACK)
+	if result.mXpos != -1 || result.mYpos != -1 {
+		t.Errorf("Search played (%d, %d) on a full board, want (-1, -1)", result.mXpos, result.mYpos)
+	}
+}
+
+func TestEvaluateBoard(t *testing.T) {
+	tests := []struct {
+		name  string
+		board [][]byte
+		sign  int // of the evaluation for black
+	}{
+		{
+			name:  "empty",
+			board: testBoard(".........", ".........", ".........", ".........", ".........", ".........", ".........", ".........", "........."),
+			sign:  0,
+		},
+		{
+			name:  "black open three",
+			board: testBoard(".........", ".........", ".........", ".........", "...XXX...", ".........", ".........", ".O.......", "........."),
+			sign:  1,
+		},
+		{
+			name:  "white open four",
+			board: testBoard(".........", ".........", ".........", "X........", "..OOOO...", "X........", ".........", "X........", "........."),
+			sign:  -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewEngine(testEngineConfig(), 1)
+			black := e.EvaluateBoard(tt.board, STONE_BLACK)
+			white := e.EvaluateBoard(tt.board, STONE_WHITE)
+
+			if testSign(black) != tt.sign || testSign(white) != -tt.sign {
+				t.Errorf("evaluation %d for black and %d for white, want signs %d and %d", black, white, tt.sign, -tt.sign)
+			}
+		})
+	}
+}
+
+func testSign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"time"
+)
+
+// GameClock keeps how much thinking time each player has used so far.
+type GameClock struct {
+	mStartTime     time.Time
+	mTurnStartTime time.Time
+	mTurn          StoneType
+	mUsed          [3]time.Duration // indexed by StoneType
+	mTurnElapsed   time.Duration    // time of the current turn before the last pause
+	mPaused        bool
+	mTimeControl   TimeControl
+	mBank          [3]time.Duration // time left at the start of each player's turn, with time control
+}
+
+func (c *GameClock) Start(turn StoneType, now time.Time) {
+	c.mStartTime = now
+	c.mTurnStartTime = now
+	c.mTurn = turn
+	c.mBank[STONE_BLACK] = c.mTimeControl.mMainTime
+	c.mBank[STONE_WHITE] = c.mTimeControl.mMainTime
+}
+
+// Switch charges the elapsed time to the player on turn and starts the clock of the next one.
+// Returns the think time of the finished turn.
+func (c *GameClock) Switch(next StoneType, now time.Time) time.Duration {
+	prev := c.mTurn
+	thinkTime := c.Stop(now)
+	if prev != STONE_NONE {
+		c.mBank[prev] += c.mTimeControl.mIncrement
+	}
+	c.mTurn = next
+	c.mTurnStartTime = now
+	return thinkTime
+}
+
+func (c *GameClock) Stop(now time.Time) time.Duration {
+	if c.mTurn == STONE_NONE {
+		return 0
+	}
+
+	thinkTime := c.mTurnElapsed
+	if !c.mPaused {
+		thinkTime += now.Sub(c.mTurnStartTime)
+	}
+	c.mUsed[c.mTurn] += thinkTime
+	c.mBank[c.mTurn] -= thinkTime
+	c.mTurn = STONE_NONE
+	c.mTurnElapsed = 0
+	c.mPaused = false
+	return thinkTime
+}
+
+// Pause freezes the clock of the player on turn. Time until Resume isn't charged to anyone.
+func (c *GameClock) Pause(now time.Time) {
+	if c.mPaused || c.mTurn == STONE_NONE {
+		return
+	}
+
+	c.mTurnElapsed += now.Sub(c.mTurnStartTime)
+	c.mPaused = true
+}
+
+func (c *GameClock) Resume(now time.Time) {
+	if !c.mPaused {
+		return
+	}
+
+	c.mTurnStartTime = now
+	c.mPaused = false
+}
+
+// Remaining is the time st has left under time control, counting the running turn
+func (c *GameClock) Remaining(st StoneType, now time.Time) time.Duration {
+	remaining := c.mBank[st]
+	if st == c.mTurn {
+		remaining -= c.mTurnElapsed
+		if !c.mPaused {
+			remaining -= now.Sub(c.mTurnStartTime)
+		}
+	}
+	return remaining
+}
+
+func (c *GameClock) Used(st StoneType) time.Duration {
+	return c.mUsed[st]
+}
+
+func (c *GameClock) StartTime() time.Time {
+	return c.mStartTime
+}
@@ -0,0 +1,208 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+type MoveRecord struct {
+	mMoveNumber uint32
+	mXpos       int
+	mYpos       int
+	mStone      StoneType
+	mTime       time.Time
+	mThinkTime  time.Duration
+}
+
+func (gs *GameSession) RecordMove(x int, y int, st StoneType, now time.Time) {
+	next := STONE_BLACK
+	if st == STONE_BLACK {
+		next = STONE_WHITE
+	}
+	thinkTime := gs.mClock.Switch(next, now)
+
+	gs.mMoves = append(gs.mMoves, MoveRecord{
+		mMoveNumber: gs.mMoveNumber,
+		mXpos:       x,
+		mYpos:       y,
+		mStone:      st,
+		mTime:       now,
+		mThinkTime:  thinkTime,
+	})
+}
+
+// WriteGameRecord appends the game to <basePath>.sgf and <basePath>.psn.
+// Both files are registered in LogParameters so that GameLift uploads them with the process log.
+func (gs *GameSession) WriteGameRecord(basePath string) {
+	if basePath == "" {
+		return
+	}
+
+	for _, r := range []struct {
+		ext  string
+		text string
+	}{
+		{".sgf", gs.MakeSGF()},
+		{".psn", gs.MakePSN()},
+	} {
+		fp, err := os.OpenFile(basePath+r.ext, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+		if err != nil {
+			myLogger.Print("Error opening game record: ", err)
+			continue
+		}
+
+		_, err = fp.WriteString(r.text)
+		if err != nil {
+			myLogger.Print("Error writing game record: ", err)
+		}
+		fp.Close()
+	}
+
+	myLogger.Print("Game record written: ", basePath)
+}
+
+func (gs *GameSession) GetWinner() StoneType {
+	switch gs.mGameStatus {
+	case GS_GAME_OVER_BLACK_WIN:
+		return STONE_BLACK
+	case GS_GAME_OVER_WHITE_WIN:
+		return STONE_WHITE
+	}
+	return STONE_NONE
+}
+
+// GetTermination tells whether the game ended on the board or because the loser left
+func (gs *GameSession) GetTermination() string {
+	if gs.mGameStatus == GS_GAME_OVER_DRAW {
+		return "draw"
+	}
+
+	winner := gs.GetWinner()
+	if winner != STONE_NONE && gs.mBoardStatus != nil && gs.IsWin(winner) {
+		return "five"
+	}
+	return "forfeit"
+}
+
+func sgfEscape(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	return strings.ReplaceAll(s, "]", "\\]")
+}
+
+func sgfCoord(x int, y int) string {
+	return string(rune('a'+x)) + string(rune('a'+y))
+}
+
+// MakeSGF builds an SGF game tree (GM[4] is Gomoku).
+// MT is not a standard property: it carries the think time of each move in seconds, and readers ignore it.
+func (gs *GameSession) MakeSGF() string {
+	var sb strings.Builder
+
+	result := "?"
+	switch gs.GetWinner() {
+	case STONE_BLACK:
+		result = "B+"
+	case STONE_WHITE:
+		result = "W+"
+	}
+	if gs.mGameStatus == GS_GAME_OVER_DRAW {
+		result = "0"
+	}
+	if gs.GetTermination() == "forfeit" && gs.GetWinner() != STONE_NONE {
+		result += "F"
+	}
+
+	fmt.Fprintf(&sb, "(;FF[4]GM[4]CA[UTF-8]AP[gomoku-in-go]SZ[%d]RU[%s]", gs.mConfig.mBoardSize, gs.mConfig.mRule)
+	fmt.Fprintf(&sb, "DT[%s]", gs.mClock.StartTime().UTC().Format("2006-01-02"))
+	fmt.Fprintf(&sb, "PB[%s]PW[%s]", sgfEscape(gs.mPlayerBlack.GetPlayerName()), sgfEscape(gs.mPlayerWhite.GetPlayerName()))
+	fmt.Fprintf(&sb, "BR[%d]WR[%d]", gs.mPlayerBlack.GetPlayerScore(), gs.mPlayerWhite.GetPlayerScore())
+	fmt.Fprintf(&sb, "TM[%d]RE[%s]\n", gs.mConfig.mTimeControl.mMainTime/time.Second, result)
+
+	for _, m := range gs.mMoves {
+		color := "B"
+		if m.mStone == STONE_WHITE {
+			color = "W"
+		}
+		fmt.Fprintf(&sb, ";%s[%s]MT[%.3f]\n", color, sgfCoord(m.mXpos, m.mYpos), m.mThinkTime.Seconds())
+	}
+	sb.WriteString(")\n")
+
+	return sb.String()
+}
+
+func psnCoord(x int, y int) string {
+	return fmt.Sprintf("%c%d", 'a'+x, y+1)
+}
+
+// MakePSN builds a PGN-like text record with tag pairs and numbered move pairs.
+// Each move is followed by a comment with its think time and wall clock time.
+func (gs *GameSession) MakePSN() string {
+	var sb strings.Builder
+
+	result := "*"
+	switch gs.GetWinner() {
+	case STONE_BLACK:
+		result = "1-0"
+	case STONE_WHITE:
+		result = "0-1"
+	}
+	if gs.mGameStatus == GS_GAME_OVER_DRAW {
+		result = "1/2-1/2"
+	}
+
+	tags := [][2]string{
+		{"Event", "Gomoku"},
+		{"Date", gs.mClock.StartTime().UTC().Format("2006.01.02")},
+		{"Black", gs.mPlayerBlack.GetPlayerName()},
+		{"White", gs.mPlayerWhite.GetPlayerName()},
+		{"BlackRating", fmt.Sprint(gs.mPlayerBlack.GetPlayerScore())},
+		{"WhiteRating", fmt.Sprint(gs.mPlayerWhite.GetPlayerScore())},
+		{"Rule", gs.mConfig.mRule.String()},
+		{"BoardSize", fmt.Sprint(gs.mConfig.mBoardSize)},
+		{"TimeControl", gs.mConfig.mTimeControl.String()},
+		{"Result", result},
+		{"Termination", gs.GetTermination()},
+		{"BlackTimeUsed", fmt.Sprintf("%.3f", gs.mClock.Used(STONE_BLACK).Seconds())},
+		{"WhiteTimeUsed", fmt.Sprintf("%.3f", gs.mClock.Used(STONE_WHITE).Seconds())},
+	}
+	for _, tag := range tags {
+		fmt.Fprintf(&sb, "[%s \"%s\"]\n", tag[0], strings.ReplaceAll(tag[1], "\"", "'"))
+	}
+	sb.WriteString("\n")
+
+	for i, m := range gs.mMoves {
+		if i%2 == 0 {
+			fmt.Fprintf(&sb, "%d.", i/2+1)
+		}
+		fmt.Fprintf(&sb, " %s {%.3fs %s}", psnCoord(m.mXpos, m.mYpos), m.mThinkTime.Seconds(), m.mTime.UTC().Format("15:04:05.000"))
+		if i%2 == 1 {
+			sb.WriteString("\n")
+		}
+	}
+	if len(gs.mMoves)%2 == 1 {
+		sb.WriteString("\n")
+	}
+	sb.WriteString(result + "\n\n")
+
+	return sb.String()
+}
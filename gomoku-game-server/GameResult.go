@@ -0,0 +1,133 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// WinReason is why the game ended, as sent in PKT_SC_GAME_RESULT
+type WinReason byte
+
+const (
+	WR_NONE        WinReason = 0
+	WR_FIVE        WinReason = 1 // five or more in a row
+	WR_RESIGNATION WinReason = 2 // the loser left with PKT_CS_EXIT
+	WR_TIMEOUT     WinReason = 3 // the loser ran out of time
+	WR_ABANDONMENT WinReason = 4 // the loser's connection dropped and didn't come back
+	WR_DRAW        WinReason = 5 // board is full
+	WR_ADJUDICATED WinReason = 6 // the server shut down mid-game and the engine decided the result
+)
+
+var winReasonNames = []string{"none", "five", "resignation", "timeout", "abandonment", "draw", "adjudicated"}
+
+func (wr WinReason) String() string {
+	if int(wr) < len(winReasonNames) {
+		return winReasonNames[wr]
+	}
+	return "unknown"
+}
+
+// FindWinningLine returns the cells of the first winning run of st stones, from one end to the other.
+// That is five or more in a row, or exactly five with RULE_STANDARD.
+func (gs *GameSession) FindWinningLine(st StoneType) [][2]int {
+	size := len(gs.mBoardStatus)
+	stoneAt := func(x int, y int) bool {
+		return x >= 0 && x < size && y >= 0 && y < size && gs.mBoardStatus[x][y] == byte(st)
+	}
+
+	for x := 0; x < size; x++ {
+		for y := 0; y < size; y++ {
+			for _, d := range engineDirections {
+				// only count from the start of a run
+				if !stoneAt(x, y) || stoneAt(x-d[0], y-d[1]) {
+					continue
+				}
+
+				var line [][2]int
+				for i := 0; stoneAt(x+i*d[0], y+i*d[1]); i++ {
+					line = append(line, [2]int{x + i*d[0], y + i*d[1]})
+				}
+				if len(line) == 5 || (len(line) > 5 && gs.mConfig.mRule == RULE_FREESTYLE) {
+					return line
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// BroadcastGameResult sends what SendGameResult decided to both players and spectators.
+// It goes after the final board so that clients can show the result screen over it.
+func (gs *GameSession) BroadcastGameResult() {
+	outPacket := gs.MakeGameResultPacket()
+
+	for _, psess := range []GamePlayer{gs.mPlayerBlack, gs.mPlayerWhite} {
+		if false == psess.PostSend(outPacket, len(outPacket)) {
+			psess.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+
+	gs.PublishToSpectators(outPacket, false)
+}
+
+func (gs *GameSession) MakeGameResultPacket() []byte {
+	var size, ptype uint16
+
+	// GameResult message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// StoneType (1byte) winner, STONE_NONE for a draw
+	// WinReason (1byte)
+	// mRated (1byte) 0 if the result doesn't change ratings
+	// mBlackEloDiff (2byte) signed
+	// mWhiteEloDiff (2byte) signed
+	// mDuration (4byte) milliseconds from game start to game end
+	// MoveNumber (4byte)
+	// mLineLength (1byte) number of stones of the winning line, 0 unless WR_FIVE
+	// mLine (2 * mLineLength byte) x, y of each stone from one end of the line to the other
+	var outPacket [2 + 2 + 1 + 1 + 1 + 2 + 2 + 4 + 4 + 1 + 2*BOARD_SIZE]byte
+
+	size = uint16(2 + 2 + 1 + 1 + 1 + 2 + 2 + 4 + 4 + 1 + 2*len(gs.mWinningLine))
+	ptype = uint16(PKT_SC_GAME_RESULT)
+
+	var rated byte
+	if gs.mRated {
+		rated = 1
+	}
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	outPacket[4] = byte(gs.GetWinner())
+	outPacket[5] = byte(gs.mWinReason)
+	outPacket[6] = rated
+	binary.LittleEndian.PutUint16(outPacket[7:], uint16(int16(gs.mEloDiff[STONE_BLACK])))
+	binary.LittleEndian.PutUint16(outPacket[9:], uint16(int16(gs.mEloDiff[STONE_WHITE])))
+	binary.LittleEndian.PutUint32(outPacket[11:], uint32(gs.mEndTime.Sub(gs.mClock.StartTime())/time.Millisecond))
+	binary.LittleEndian.PutUint32(outPacket[15:], gs.mMoveNumber)
+	outPacket[19] = byte(len(gs.mWinningLine))
+	for i, cell := range gs.mWinningLine {
+		outPacket[20+2*i] = byte(cell[0])
+		outPacket[20+2*i+1] = byte(cell[1])
+	}
+
+	return outPacket[0:size]
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFindWinningLine(t *testing.T) {
+	tests := []struct {
+		name      string
+		board     [][]byte
+		st        StoneType
+		freestyle [][2]int
+		standard  [][2]int
+	}{
+		{
+			name: "five",
+			board: testBoard(
+				".........",
+				".XXXXX...",
+				".OOOO....",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+			),
+			st:        STONE_BLACK,
+			freestyle: [][2]int{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}},
+			standard:  [][2]int{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}},
+		},
+		{
+			name: "overline",
+			board: testBoard(
+				".........",
+				".XXXXXX..",
+				".OOOO....",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+			),
+			st:        STONE_BLACK,
+			freestyle: [][2]int{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}, {6, 1}},
+		},
+		{
+			name: "diagonal five",
+			board: testBoard(
+				"O........",
+				".O.......",
+				"..O......",
+				"...O.....",
+				"....O....",
+				".........",
+				".........",
+				".........",
+				".........",
+			),
+			st:        STONE_WHITE,
+			freestyle: [][2]int{{0, 0}, {1, 1}, {2, 2}, {3, 3}, {4, 4}},
+			standard:  [][2]int{{0, 0}, {1, 1}, {2, 2}, {3, 3}, {4, 4}},
+		},
+		{
+			name: "four",
+			board: testBoard(
+				".........",
+				".XXXX.X..",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+			),
+			st: STONE_BLACK,
+		},
+		{
+			name: "other color",
+			board: testBoard(
+				".........",
+				".XXXXX...",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+				".........",
+			),
+			st: STONE_WHITE,
+		},
+		{
+			name: "overline and a five",
+			board: testBoard(
+				"XXXXXX...",
+				".........",
+				".X.......",
+				".X.......",
+				".X.......",
+				".X.......",
+				".X.......",
+				".........",
+				".........",
+			),
+			st:        STONE_BLACK,
+			freestyle: [][2]int{{0, 0}, {1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}},
+			standard:  [][2]int{{1, 2}, {1, 3}, {1, 4}, {1, 5}, {1, 6}},
+		},
+	}
+
+	for _, tt := range tests {
+		for _, rule := range []RuleVariant{RULE_FREESTYLE, RULE_STANDARD} {
+			t.Run(tt.name+"/"+rule.String(), func(t *testing.T) {
+				gs := &GameSession{mBoardStatus: tt.board}
+				gs.mConfig.mRule = rule
+
+				want := tt.freestyle
+				if rule == RULE_STANDARD {
+					want = tt.standard
+				}
+				if got := gs.FindWinningLine(tt.st); !reflect.DeepEqual(got, want) {
+					t.Errorf("FindWinningLine = %v, want %v", got, want)
+				}
+			})
+		}
+	}
+}
@@ -19,11 +19,12 @@
 package main
 
 import (
-	"bytes"
 	"encoding/binary"
-	"fmt"
 	"math"
 	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type StoneType byte
@@ -41,9 +42,11 @@ const (
 	GS_STARTED             GameStatus = 1
 	GS_GAME_OVER_BLACK_WIN GameStatus = 2
 	GS_GAME_OVER_WHITE_WIN GameStatus = 3
+	GS_GAME_OVER_DRAW      GameStatus = 4 // board is full without five in a row
+	GS_PAUSED              GameStatus = 5 // both clocks stopped, no moves until resumed
 )
 
-const BOARD_SIZE = 19
+const BOARD_SIZE = 19 // default and largest board size. See SessionConfig
 
 const MAX_SESSION_LEN = 128
 const MAX_STRING_LEN = 64
@@ -58,13 +61,40 @@ const (
 	PKT_CS_START PacketTypes = 1
 	PKT_SC_START PacketTypes = 2
 
+	PKT_CS_CAPABILITIES PacketTypes = 3 // Client announces optional protocol features. See ClientCapability.
+	PKT_SC_CAPABILITIES PacketTypes = 4 // Features the server enabled for this client.
+
+	PKT_CS_SPECTATE       PacketTypes = 11 // Join as spectator with a spectator token
+	PKT_SC_SPECTATE_START PacketTypes = 12 // Player names, sent to spectators instead of PKT_SC_START
+
 	PKT_CS_PUT_STONE    PacketTypes = 21
 	PKT_SC_BOARD_STATUS PacketTypes = 22
+	PKT_CS_RESYNC       PacketTypes = 23 // Request a full PKT_SC_BOARD_STATUS
+	PKT_SC_STONE_PLACED PacketTypes = 24 // Sent instead of PKT_SC_BOARD_STATUS after a move to clients with CAP_DELTA_BOARD
 
 	PKT_CS_EXIT PacketTypes = 31
 
 	PKT_CS_PING PacketTypes = 41 // Client Ping message to Keep connected when global accelerator is used.
 
+	PKT_SC_ERROR PacketTypes = 51 // Server rejected a client request. See ErrorCode.
+
+	PKT_CS_CHAT  PacketTypes = 61
+	PKT_SC_CHAT  PacketTypes = 62 // Relayed to the opponent and spectators
+	PKT_CS_EMOTE PacketTypes = 63
+	PKT_SC_EMOTE PacketTypes = 64
+	PKT_CS_MUTE  PacketTypes = 65 // Stop or resume receiving the opponent's chat and emotes
+
+	PKT_CS_PAUSE_REQUEST PacketTypes = 71
+	PKT_SC_PAUSE_REQUEST PacketTypes = 72 // Opponent asks for a pause. Answer with PKT_CS_PAUSE_ACCEPT or ignore
+	PKT_CS_PAUSE_ACCEPT  PacketTypes = 73
+	PKT_CS_RESUME        PacketTypes = 74 // Either player may end a pause. Pause state is in the GameStatus of PKT_SC_BOARD_STATUS
+
+	PKT_SC_CONNECTION_STATUS PacketTypes = 81 // The opponent dropped, is reconnecting, reconnected or timed out. See ConnectionStatus
+
+	PKT_SC_GAME_RESULT PacketTypes = 91 // Sent after the final board. See WinReason
+
+	PKT_SC_SERVER_SHUTDOWN PacketTypes = 95 // The server process is going away. A game still running by then is adjudicated
+
 	/// Client and MatchMaker
 	PKT_CM_MATCH_REQUEST PacketTypes = 101
 	PKT_MC_WAIT          PacketTypes = 102
@@ -73,28 +103,112 @@ const (
 	PKT_MAX PacketTypes = 1024
 )
 
+// ClientCapability is a bit flag negotiated with PKT_CS_CAPABILITIES
+type ClientCapability uint32
+
+const (
+	CAP_DELTA_BOARD      ClientCapability = 1 << 0 // receive PKT_SC_STONE_PLACED instead of full board after each move
+	CAP_COLOR_ASSIGNMENT ClientCapability = 1 << 1 // receive the color assignment method in PKT_SC_START
+	CAP_SESSION_CONFIG   ClientCapability = 1 << 2 // receive the session config in PKT_SC_START and mBoardSize * mBoardSize cells in PKT_SC_BOARD_STATUS
+	CAP_MOVE_NUMBER      ClientCapability = 1 << 3 // receive the move number at the end of PKT_SC_BOARD_STATUS
+
+	CAP_SUPPORTED = CAP_DELTA_BOARD | CAP_COLOR_ASSIGNMENT | CAP_SESSION_CONFIG | CAP_MOVE_NUMBER
+)
+
+// ErrorCode is the machine-readable reason carried by PKT_SC_ERROR
+type ErrorCode uint16
+
+const (
+	EC_NONE               ErrorCode = 0
+	EC_OUT_OF_RANGE       ErrorCode = 1
+	EC_NOT_YOUR_TURN      ErrorCode = 2
+	EC_OCCUPIED           ErrorCode = 3
+	EC_GAME_NOT_STARTED   ErrorCode = 4
+	EC_GAME_OVER          ErrorCode = 5
+	EC_UNAUTHENTICATED    ErrorCode = 6
+	EC_PROTOCOL_VIOLATION ErrorCode = 7
+	EC_STALE_BOARD        ErrorCode = 8 // expected board version doesn't match. mMoveNumber in PKT_SC_ERROR has the current one
+	EC_NOT_SEATED         ErrorCode = 9 // request needs a seat at the board. Spectators are read-only
+	EC_SPECTATORS_FULL    ErrorCode = 10
+	EC_RATE_LIMITED       ErrorCode = 11
+	EC_MESSAGE_TOO_LONG   ErrorCode = 12
+	EC_MESSAGE_REJECTED   ErrorCode = 13 // dropped by the chat filter
+	EC_GAME_PAUSED        ErrorCode = 14
+	EC_PAUSE_LIMIT        ErrorCode = 15 // the requester used up the maximum pause time
+	EC_NO_PAUSE_REQUEST   ErrorCode = 16 // nothing to accept
+	EC_NOT_PAUSED         ErrorCode = 17
+	EC_MATCH_CANCELLED    ErrorCode = 18 // no replacement found for a player who left before the game started
+	EC_SHUTTING_DOWN      ErrorCode = 19 // the server process is going away and takes nobody new
+)
+
 type BoardStatus struct {
 	mBoardMatrix [BOARD_SIZE][BOARD_SIZE]StoneType
 }
 
+// GamePlayer is what GameSession needs from whoever sits at the board.
+// PlayerSession is the network implementation.
+type GamePlayer interface {
+	GetPlayerSessionId() string
+	GetPlayerName() string
+	GetPlayerScore() int
+	HasCapability(c ClientCapability) bool
+	PostSend(data []byte, len int) bool
+	Disconnect(dr DisconnectReason)
+}
+
 type GameSession struct {
-	mPlayerBlack *PlayerSession
-	mPlayerWhite *PlayerSession
+	mPlayerBlack GamePlayer
+	mPlayerWhite GamePlayer
 
 	mGameStatus  GameStatus
-	mBoardStatus [][]byte // BoardStatus. Will be initialized to [BOARD_SIZE][BOARD_SIZE]
+	mBoardStatus [][]byte // BoardStatus. Will be initialized to [mBoardSize][mBoardSize]
 	mCurrentTurn StoneType
+	mMoveNumber  uint32 // number of stones placed so far. Also used as board version
+	mMoves       []MoveRecord
+	mClock       GameClock
+	mHasBot      bool // a BotPlayer took a seat at some point. Such games are unrated
+	mConfig      SessionConfig
+	mTurnTimer   *time.Timer // ends the game when the player on turn runs out of time
+
+	mColorAssignment ColorAssignment // configured method until the game starts, then the one actually used
+	mColorSeed       int64
+
+	mChat [3]ChatState // indexed by StoneType
+
+	mPauseRequestedBy StoneType // pending pause request. Expires with the next move
+	mPausedBy         StoneType // whose pause time the running pause uses
+	mPauseStart       time.Time
+	mPauseUsed        [3]time.Duration // indexed by StoneType
+	mPauseCount       int              // tells the auto resume timer of an earlier pause it's stale
+	mPauseTimer       *time.Timer
+
+	mDisconnected [3]*SeatDisconnect // seats kept for a reconnect, indexed by StoneType
+
+	/// decided by SendGameResult
+	mWinReason   WinReason
+	mWinningLine [][2]int
+	mEloDiff     [3]int // indexed by StoneType
+	mRated       bool
+	mEndTime     time.Time
+
+	mSpectatorLock   sync.Mutex // spectators are fed from their own goroutines
+	mSpectators      []*Spectator
+	mSpectatorFrames []SpectatorFrame // everything published to spectators so far, oldest first
 
 	mGameLiftManager *GameLiftManager
 }
 
-func (gs *GameSession) PlayerEnter(psess *PlayerSession) {
+func (gs *GameSession) PlayerEnter(psess GamePlayer) {
 	// FastSpinlockGuard lock(mGameSessionLock);
 
 	if gs.mGameStatus != GS_NOT_STARTED {
 		myLogger.Print("[PlayerEnter Denied] Game has already started.\n", psess.GetPlayerSessionId())
 		return
 	}
+	if IsBot(psess) {
+		gs.mHasBot = true
+	}
+
 	// Make first connected Player as Black one.
 	if gs.mPlayerBlack != nil {
 		/// Game Ready!
@@ -108,20 +222,37 @@ func (gs *GameSession) PlayerEnter(psess *PlayerSession) {
 	}
 }
 
-func (gs *GameSession) PlayerLeave(psess *PlayerSession) {
+func (gs *GameSession) PlayerLeave(psess GamePlayer, reason WinReason) {
 	// FastSpinlockGuard lock(mGameSessionLock);
 
+	/// free the seat for the next player
+	if gs.mGameStatus == GS_NOT_STARTED && psess == gs.mPlayerBlack {
+		gs.mPlayerBlack = nil
+		gs.mGameLiftManager.mPlayerReadyCount--
+		return
+	}
+
+	/// a player who leaves can't agree to resume
+	gs.ResumeGame(time.Now())
+
 	if gs.mGameStatus == GS_STARTED {
+		/// let a bot finish the game for the player who left, unless nobody would be left to play against
+		if gs.mGameLiftManager.mBotReplace && !IsBot(gs.Opponent(psess)) {
+			gs.ReplaceWithBot(psess)
+			return
+		}
+
 		/// giveup
 		if psess == gs.mPlayerBlack {
 			gs.mGameStatus = GS_GAME_OVER_WHITE_WIN
-			gs.SendGameResult(false)
+			gs.SendGameResult(STONE_WHITE, reason)
 		} else {
 			gs.mGameStatus = GS_GAME_OVER_BLACK_WIN
-			gs.SendGameResult(true)
+			gs.SendGameResult(STONE_BLACK, reason)
 		}
 
 		gs.BroadcastGameStatus()
+		gs.BroadcastGameResult()
 	}
 
 	/* doesn't have to release memory with go
@@ -133,15 +264,81 @@ func (gs *GameSession) PlayerLeave(psess *PlayerSession) {
 	*/
 }
 
-func (gs *GameSession) PutStone(psess *PlayerSession, x int, y int) {
-	if x < 0 || x >= BOARD_SIZE || y < 0 || y >= BOARD_SIZE {
+// SubmitMove is PutStone for clients sending a move sequence number and the board version they saw.
+// A move whose sequence number was already accepted is ignored and the board is sent again,
+// so that clients can safely resend after a timeout or a reconnect.
+func (gs *GameSession) SubmitMove(psess *PlayerSession, x int, y int, moveSeq uint32, boardVersion uint32) ErrorCode {
+	if moveSeq != 0 && moveSeq <= psess.mLastMoveSeq {
+		myLogger.Printf("[SubmitMove] Duplicate move seq %d (last %d) from %s\n", moveSeq, psess.mLastMoveSeq, psess.GetPlayerSessionId())
+		if gs.mGameStatus != GS_NOT_STARTED {
+			gs.SendGameStatus(psess)
+		}
+		return EC_NONE
+	}
+
+	if gs.mGameStatus == GS_STARTED && boardVersion != gs.mMoveNumber {
+		myLogger.Printf("[SubmitMove Denied] Stale board version %d (current %d) from %s\n", boardVersion, gs.mMoveNumber, psess.GetPlayerSessionId())
+		return EC_STALE_BOARD
+	}
+
+	ec := gs.PutStone(psess, x, y)
+	if ec == EC_NONE {
+		psess.mLastMoveSeq = moveSeq
+	}
+
+	return ec
+}
+
+func (gs *GameSession) Opponent(psess GamePlayer) GamePlayer {
+	if psess == gs.mPlayerBlack {
+		return gs.mPlayerWhite
+	}
+	return gs.mPlayerBlack
+}
+
+func (gs *GameSession) ReplaceWithBot(psess GamePlayer) {
+	bot := NewBotPlayer(gs.mGameLiftManager.mBotLevel, gs, time.Now().UnixNano())
+
+	if psess == gs.mPlayerBlack {
+		gs.mPlayerBlack = bot
+	} else {
+		gs.mPlayerWhite = bot
+	}
+	gs.mHasBot = true
+
+	myLogger.Printf("[BOT] %s replaces %s who left\n", bot.GetPlayerName(), psess.GetPlayerSessionId())
+
+	bot.CheckTurn()
+}
+
+func (gs *GameSession) IsRated() bool {
+	return !gs.mHasBot && gs.mConfig.mRanked
+}
+
+func (gs *GameSession) PutStone(psess GamePlayer, x int, y int) ErrorCode {
+	if x < 0 || x >= gs.mConfig.mBoardSize || y < 0 || y >= gs.mConfig.mBoardSize {
 		myLogger.Print("[PutStone Denied] out of range\n", psess.GetPlayerSessionId())
-		return
+		return EC_OUT_OF_RANGE
 	}
 
-	if gs.mGameStatus != GS_STARTED {
+	if gs.mGameStatus == GS_NOT_STARTED {
 		myLogger.Print("[PutStone Denied] Not started game\n", psess.GetPlayerSessionId())
-		return
+		return EC_GAME_NOT_STARTED
+	}
+
+	if gs.mGameStatus == GS_PAUSED {
+		myLogger.Print("[PutStone Denied] Game is paused\n", psess.GetPlayerSessionId())
+		return EC_GAME_PAUSED
+	}
+
+	if gs.mGameStatus != GS_STARTED {
+		myLogger.Print("[PutStone Denied] Game is over\n", psess.GetPlayerSessionId())
+		return EC_GAME_OVER
+	}
+
+	if psess != gs.mPlayerBlack && psess != gs.mPlayerWhite {
+		myLogger.Print("[PutStone Denied] Not seated\n", psess.GetPlayerSessionId())
+		return EC_NOT_SEATED
 	}
 
 	// FastSpinlockGuard lock(mGameSessionLock);
@@ -150,17 +347,17 @@ func (gs *GameSession) PutStone(psess *PlayerSession, x int, y int) {
 
 	if isBlack && gs.mCurrentTurn != STONE_BLACK {
 		myLogger.Print("[PutStone Denied] Turn mismatch\n", psess.GetPlayerSessionId())
-		return
+		return EC_NOT_YOUR_TURN
 	}
 
 	if !isBlack && gs.mCurrentTurn != STONE_WHITE {
 		myLogger.Print("[PutStone Denied] Turn mismatch\n", psess.GetPlayerSessionId())
-		return
+		return EC_NOT_YOUR_TURN
 	}
 
 	if gs.mBoardStatus[x][y] != byte(STONE_NONE) {
 		myLogger.Print("[PutStone Denied] wrong position\n", psess.GetPlayerSessionId())
-		return
+		return EC_OCCUPIED
 	}
 
 	var st StoneType
@@ -173,6 +370,9 @@ func (gs *GameSession) PutStone(psess *PlayerSession, x int, y int) {
 	}
 
 	gs.mBoardStatus[x][y] = byte(st)
+	gs.mMoveNumber++
+	gs.mPauseRequestedBy = STONE_NONE
+	gs.RecordMove(x, y, st, time.Now())
 
 	/// Win check...
 	if gs.IsWin(st) {
@@ -181,7 +381,10 @@ func (gs *GameSession) PutStone(psess *PlayerSession, x int, y int) {
 		} else {
 			gs.mGameStatus = GS_GAME_OVER_WHITE_WIN
 		}
-		gs.SendGameResult(isBlack)
+		gs.SendGameResult(st, WR_FIVE)
+	} else if gs.mMoveNumber == uint32(gs.mConfig.mBoardSize*gs.mConfig.mBoardSize) {
+		gs.mGameStatus = GS_GAME_OVER_DRAW
+		gs.SendGameResult(STONE_NONE, WR_DRAW)
 	}
 
 	if isBlack {
@@ -190,143 +393,221 @@ func (gs *GameSession) PutStone(psess *PlayerSession, x int, y int) {
 		gs.mCurrentTurn = STONE_BLACK
 	}
 
-	gs.BroadcastGameStatus()
+	gs.BroadcastStonePlaced(x, y, st)
+
+	if gs.IsEnd() {
+		gs.BroadcastGameResult()
+	} else {
+		gs.ArmTurnTimer()
+	}
+
+	return EC_NONE
 }
 
 func (gs *GameSession) BroadcastGameStart() {
-	var size, ptype uint16
-	// GameStartBroadcast message structure
-	// mSize (2byte)
-	// mType (2byte)
-	// mFirstPlayerId (MAX_SESSION_LEN byte)
-	// mOpponentName (MAX_STRING_LEN byte)
-	var outPacketToBlack, outPacketToWhite [2 + 2 + MAX_SESSION_LEN + MAX_STRING_LEN]byte
-
 	if gs.mGameStatus != GS_STARTED {
 		myLogger.Fatal("BroadcastGameStart Error Not GS_STARTED")
 	}
 
-	fmt.Println("BroadcastGameStart() gs.mPlayerBlack: ", gs.mPlayerBlack)
+	gs.AssignColors()
 
-	size = 2 + 2 + MAX_SESSION_LEN + MAX_STRING_LEN
-	ptype = uint16(PKT_SC_START)
+	myLogger.Print("BroadcastGameStart() gs.mPlayerBlack: ", gs.mPlayerBlack.GetPlayerSessionId())
+
+	gs.SendGameStart(gs.mPlayerBlack)
+	gs.SendGameStart(gs.mPlayerWhite)
 
-	binary.LittleEndian.PutUint16(outPacketToBlack[0:], size)
-	binary.LittleEndian.PutUint16(outPacketToBlack[2:], ptype)
-	copy(outPacketToBlack[4:], gs.mPlayerBlack.GetPlayerSessionId())
-	copy(outPacketToBlack[(4+MAX_SESSION_LEN):], gs.mPlayerWhite.GetPlayerName())
+	// Initialize BoardStatus
+	gs.mBoardStatus = make([][]byte, gs.mConfig.mBoardSize)
+	for i := 0; i < gs.mConfig.mBoardSize; i++ {
+		gs.mBoardStatus[i] = make([]byte, gs.mConfig.mBoardSize)
+	}
+	gs.mClock.mTimeControl = gs.mConfig.mTimeControl
+	gs.mClock.Start(gs.mCurrentTurn, time.Now())
+	gs.ArmTurnTimer()
 
-	if false == gs.mPlayerBlack.PostSend(outPacketToBlack[0:size], int(size)) {
-		gs.mPlayerBlack.Disconnect(DR_SENDBUFFER_ERROR)
+	// Initial sync for clients which only get deltas afterwards
+	if gs.mPlayerBlack.HasCapability(CAP_DELTA_BOARD) {
+		gs.SendGameStatus(gs.mPlayerBlack)
 	}
+	if gs.mPlayerWhite.HasCapability(CAP_DELTA_BOARD) {
+		gs.SendGameStatus(gs.mPlayerWhite)
+	}
+
+	gs.PublishToSpectators(gs.MakeSpectateStartPacket(), false)
+	gs.PublishToSpectators(gs.MakeGameStatusPacket(BOARD_SIZE, false), true)
+}
+
+// SendGameStart tells psess who plays black and who the opponent is
+func (gs *GameSession) SendGameStart(psess GamePlayer) {
+	var size, ptype uint16
+	// GameStartBroadcast message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// mFirstPlayerId (MAX_SESSION_LEN byte) black's player session ID for black, black's player ID for white
+	// mOpponentName (MAX_STRING_LEN byte)
+	// mColorAssignment (1byte, only with CAP_COLOR_ASSIGNMENT) how the colors were decided. See ColorAssignment
+	// mBoardSize (1byte, this and the following only with CAP_SESSION_CONFIG)
+	// mRule (1byte) see RuleVariant
+	// mMainTime (4byte) time control main time in milliseconds, 0 for no time limit
+	// mIncrement (4byte) time control increment in milliseconds
+	var outPacket [2 + 2 + MAX_SESSION_LEN + MAX_STRING_LEN + 1 + 1 + 1 + 4 + 4]byte
 
-	binary.LittleEndian.PutUint16(outPacketToWhite[0:], size)
-	binary.LittleEndian.PutUint16(outPacketToWhite[2:], ptype)
-	copy(outPacketToWhite[4:], gs.mPlayerBlack.GetPlayerSessionId())
-	copy(outPacketToWhite[(4+MAX_SESSION_LEN):], gs.mPlayerBlack.GetPlayerName())
+	ptype = uint16(PKT_SC_START)
 
-	if false == gs.mPlayerWhite.PostSend(outPacketToWhite[0:2+2+MAX_SESSION_LEN+MAX_STRING_LEN], int(size)) {
-		gs.mPlayerWhite.Disconnect(DR_SENDBUFFER_ERROR)
+	offset := 4 + MAX_SESSION_LEN + MAX_STRING_LEN
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	/// never the opponent's player session ID. It is what takes a seat back on reconnect.
+	/// White gets the player ID instead, which it sees as the opponent name anyway
+	if psess == gs.mPlayerBlack {
+		copy(outPacket[4:], gs.mPlayerBlack.GetPlayerSessionId())
+	} else {
+		copy(outPacket[4:], gs.mPlayerBlack.GetPlayerName())
+	}
+	copy(outPacket[(4+MAX_SESSION_LEN):], gs.Opponent(psess).GetPlayerName())
+	/// Older clients read a fixed size PKT_SC_START, so the extra fields go only to clients asking for them
+	if psess.HasCapability(CAP_COLOR_ASSIGNMENT) {
+		outPacket[offset] = byte(gs.mColorAssignment)
+		offset += 1
+	}
+	if psess.HasCapability(CAP_SESSION_CONFIG) {
+		outPacket[offset] = byte(gs.mConfig.mBoardSize)
+		outPacket[offset+1] = byte(gs.mConfig.mRule)
+		binary.LittleEndian.PutUint32(outPacket[offset+2:], uint32(gs.mConfig.mTimeControl.mMainTime/time.Millisecond))
+		binary.LittleEndian.PutUint32(outPacket[offset+6:], uint32(gs.mConfig.mTimeControl.mIncrement/time.Millisecond))
+		offset += 1 + 1 + 4 + 4
 	}
 
-	// Initialize BoardStatus
-	gs.mBoardStatus = make([][]byte, BOARD_SIZE)
-	for i := 0; i < BOARD_SIZE; i++ {
-		gs.mBoardStatus[i] = make([]byte, BOARD_SIZE)
+	size = uint16(offset)
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+
+	if false == psess.PostSend(outPacket[0:size], int(size)) {
+		psess.Disconnect(DR_SENDBUFFER_ERROR)
 	}
 }
 
-func (gs *GameSession) BroadcastGameStatus() {
+// MakeGameStatusPacket lays the board out in boardSize * boardSize cells, which is either mBoardSize
+// or BOARD_SIZE for clients without CAP_SESSION_CONFIG. Smaller boards are then padded with empty cells.
+// The move number is only added for clients with CAP_MOVE_NUMBER.
+func (gs *GameSession) MakeGameStatusPacket(boardSize int, withMoveNumber bool) []byte {
 	var size, ptype uint16
 
 	// BroadcastGameStatus message structure
 	// mSize (2byte)
 	// mType (2byte)
-	// BoardStatus (BOARD_SIZE * BOARD_SIZE byte)
+	// BoardStatus (boardSize * boardSize byte)
 	// GameStatus (1byte)
 	// StoneType (1byte)
-	//var outPacket []byte
-	var outPacket [2 + 2 + (BOARD_SIZE * BOARD_SIZE) + 1 + 1]byte
+	// MoveNumber (4byte, only with CAP_MOVE_NUMBER) authoritative move number, i.e. the board version
+	cells := boardSize * boardSize
+	outPacket := make([]byte, 2+2+cells+1+1+4)
 
-	fmt.Println("BroadcastGameStatus()")
-
-	size = 2 + 2 + (BOARD_SIZE * BOARD_SIZE) + 1 + 1
+	size = uint16(2 + 2 + cells + 1 + 1)
+	if withMoveNumber {
+		size += 4
+	}
 	ptype = uint16(PKT_SC_BOARD_STATUS)
 
 	binary.LittleEndian.PutUint16(outPacket[0:], size)
 	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
-	copy(outPacket[4:], bytes.Join(gs.mBoardStatus, nil))
-	outPacket[(4 + BOARD_SIZE*BOARD_SIZE)] = byte(gs.mGameStatus)
-	outPacket[(4+BOARD_SIZE*BOARD_SIZE)+1] = byte(gs.mCurrentTurn)
+	for i, row := range gs.mBoardStatus {
+		copy(outPacket[4+i*boardSize:], row)
+	}
+	outPacket[(4 + cells)] = byte(gs.mGameStatus)
+	outPacket[(4+cells)+1] = byte(gs.mCurrentTurn)
+	if withMoveNumber {
+		binary.LittleEndian.PutUint32(outPacket[(4+cells)+2:], gs.mMoveNumber)
+	}
+
+	return outPacket[0:size]
+}
 
-	if false == gs.mPlayerBlack.PostSend(outPacket[0:size], int(size)) {
-		gs.mPlayerBlack.Disconnect(DR_SENDBUFFER_ERROR)
+func (gs *GameSession) SendGameStatus(psess GamePlayer) {
+	boardSize := BOARD_SIZE
+	if psess.HasCapability(CAP_SESSION_CONFIG) {
+		boardSize = gs.mConfig.mBoardSize
 	}
+	outPacket := gs.MakeGameStatusPacket(boardSize, psess.HasCapability(CAP_MOVE_NUMBER))
 
-	if false == gs.mPlayerWhite.PostSend(outPacket[0:size], int(size)) {
-		gs.mPlayerWhite.Disconnect(DR_SENDBUFFER_ERROR)
+	if false == psess.PostSend(outPacket, len(outPacket)) {
+		psess.Disconnect(DR_SENDBUFFER_ERROR)
 	}
 }
 
-func (gs *GameSession) IsWin(st StoneType) bool {
+func (gs *GameSession) BroadcastGameStatus() {
+	myLogger.Print("BroadcastGameStatus()")
 
-	for l := 0; l < BOARD_SIZE; l++ {
-		for i1 := 0; i1 < BOARD_SIZE; i1++ {
-			if l < BOARD_SIZE-4 && gs.CheckLine(st, l, i1, 1, 0) {
-				return true
-			}
+	gs.SendGameStatus(gs.mPlayerBlack)
+	gs.SendGameStatus(gs.mPlayerWhite)
+	gs.PublishToSpectators(gs.MakeGameStatusPacket(BOARD_SIZE, false), true)
+}
 
-			if l < BOARD_SIZE-4 && i1 < BOARD_SIZE-4 && gs.CheckLine(st, l, i1, 1, 1) {
-				return true
-			}
+func (gs *GameSession) MakeStonePlacedPacket(x int, y int, st StoneType) []byte {
+	var size, ptype uint16
 
-			if i1 < BOARD_SIZE-4 && gs.CheckLine(st, l, i1, 0, 1) {
-				return true
-			}
+	// StonePlaced message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// mXpos (1byte)
+	// mYpos (1byte)
+	// StoneType (1byte) color of the stone just placed
+	// GameStatus (1byte)
+	// StoneType (1byte) next turn
+	// MoveNumber (4byte)
+	var outPacket [2 + 2 + 1 + 1 + 1 + 1 + 1 + 4]byte
 
-			if l <= 3 || i1 >= BOARD_SIZE-4 || !gs.CheckLine(st, l, i1, -1, 1) {
-				continue
-			}
+	size = 2 + 2 + 1 + 1 + 1 + 1 + 1 + 4
+	ptype = uint16(PKT_SC_STONE_PLACED)
 
-			return true
-		}
-	}
-
-	return false
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	outPacket[4] = byte(x)
+	outPacket[5] = byte(y)
+	outPacket[6] = byte(st)
+	outPacket[7] = byte(gs.mGameStatus)
+	outPacket[8] = byte(gs.mCurrentTurn)
+	binary.LittleEndian.PutUint32(outPacket[9:], gs.mMoveNumber)
+
+	return outPacket[0:size]
 }
 
-func (gs GameSession) CheckLine(st StoneType, i int, j int, l int, i1 int) bool {
-	var j1 int = 0
+// BroadcastStonePlaced notifies each player of a move either as delta or as full board, depending on the client capability
+func (gs *GameSession) BroadcastStonePlaced(x int, y int, st StoneType) {
+	outPacket := gs.MakeStonePlacedPacket(x, y, st)
 
-	for {
-		if gs.mBoardStatus[i+j1*l][j+j1*i1] != byte(st) {
-			return false
+	for _, psess := range []GamePlayer{gs.mPlayerBlack, gs.mPlayerWhite} {
+		if !psess.HasCapability(CAP_DELTA_BOARD) {
+			gs.SendGameStatus(psess)
+			continue
 		}
-		j1++
-		if j1 == 5 {
-			break
+
+		if false == psess.PostSend(outPacket, len(outPacket)) {
+			psess.Disconnect(DR_SENDBUFFER_ERROR)
 		}
 	}
 
-	return true
+	// Spectators always get the full board in the BOARD_SIZE layout without the move number, as all of them share the same frames.
+	// Any frame is then a good starting point for a late joiner
+	gs.PublishToSpectators(gs.MakeGameStatusPacket(BOARD_SIZE, false), true)
 }
 
-func (gs *GameSession) CalcEloScore(myScore int, opponentScore int, win bool) int {
-	var K int = 100
+// IsWin checks the whole board for a winning line under the rule of the game session
+func (gs *GameSession) IsWin(st StoneType) bool {
+	return gs.FindWinningLine(st) != nil
+}
+
+// CalcEloScore returns the rating change. actual is 1 for a win, 0.5 for a draw and 0 for a loss
+func (gs *GameSession) CalcEloScore(myScore int, opponentScore int, actual float64) int {
+	var K int = gs.mConfig.mKFactor
 	var result float64
 	var expected float64
 
 	expected = 1 / (1 + math.Pow(10, (float64(myScore-opponentScore)/400)))
-	if win {
-		result = math.Round(float64(myScore) + float64(K)*(float64(1)-expected))
-	} else {
-		result = math.Round(float64(myScore) + float64(K)*(float64(0)-expected))
-	}
+	result = math.Round(float64(myScore) + float64(K)*(actual-expected))
 
 	return int(result) - myScore
 }
 
-func (gs *GameSession) MakeResultJsonString(playerName string, scorediff int, windiff int, losediff int) string {
+func (gs *GameSession) MakeResultJsonString(playerName string, scorediff int, windiff int, losediff int, rated bool, analysis string) string {
 	var ss string
 
 	ss = "{ \"PlayerName\" : \""
@@ -337,6 +618,15 @@ func (gs *GameSession) MakeResultJsonString(playerName string, scorediff int, wi
 	ss += strconv.Itoa(losediff)
 	ss += ", \"ScoreDiff\" : "
 	ss += strconv.Itoa(scorediff)
+	ss += ", \"Rated\" : "
+	ss += strconv.FormatBool(rated)
+	ss += ", \"ColorAssignment\" : \""
+	ss += gs.mColorAssignment.String()
+	ss += "\""
+	if analysis != "" {
+		ss += ", \"Analysis\" : "
+		ss += analysis
+	}
 	ss += " }"
 
 	return ss
@@ -351,29 +641,89 @@ func (gs *GameSession) MakeResultJsonString(playerName string, scorediff int, wi
 	*/
 }
 
-func (gs *GameSession) SendGameResult(isBlackWin bool) {
+// SendGameResult reports the finished game. winner is STONE_NONE for a draw
+func (gs *GameSession) SendGameResult(winner StoneType, reason WinReason) {
 
-	var blackJson, whiteJson string
+	var blackWin, blackLose, whiteWin, whiteLose int
+	var blackActual float64
 
-	blackNew := gs.CalcEloScore(gs.mPlayerBlack.GetPlayerScore(), gs.mPlayerWhite.GetPlayerScore(), isBlackWin)
-	whiteNew := gs.CalcEloScore(gs.mPlayerWhite.GetPlayerScore(), gs.mPlayerBlack.GetPlayerScore(), !isBlackWin)
+	/// the engine's call on an unfinished game shouldn't cost anybody rating
+	rated := gs.IsRated() && reason != WR_ADJUDICATED
 
-	if isBlackWin {
-		myLogger.Printf("[GAME OVER] Player %s Win!\n", gs.mPlayerBlack.mPlayerSessionId)
+	switch winner {
+	case STONE_BLACK:
+		blackActual = 1
+	case STONE_WHITE:
+		blackActual = 0
+	default:
+		blackActual = 0.5
+	}
 
-		blackJson = gs.MakeResultJsonString(gs.mPlayerBlack.mPlayerName, blackNew, 1, 0)
-		whiteJson = gs.MakeResultJsonString(gs.mPlayerWhite.mPlayerName, whiteNew, 0, 1)
-	} else {
-		myLogger.Printf("[GAME OVER] Player %s Win!\n", gs.mPlayerWhite.mPlayerSessionId)
+	blackNew := gs.CalcEloScore(gs.mPlayerBlack.GetPlayerScore(), gs.mPlayerWhite.GetPlayerScore(), blackActual)
+	whiteNew := gs.CalcEloScore(gs.mPlayerWhite.GetPlayerScore(), gs.mPlayerBlack.GetPlayerScore(), 1-blackActual)
 
-		blackJson = gs.MakeResultJsonString(gs.mPlayerBlack.mPlayerName, blackNew, 0, 1)
-		whiteJson = gs.MakeResultJsonString(gs.mPlayerWhite.mPlayerName, whiteNew, 1, 0)
+	if !rated {
+		blackNew, whiteNew = 0, 0
 	}
 
-	/// Send to SQS
-	gs.mGameLiftManager.SendGameResultToSQS(blackJson, whiteJson)
+	switch winner {
+	case STONE_BLACK:
+		myLogger.Printf("[GAME OVER] Player %s Win!\n", gs.mPlayerBlack.GetPlayerSessionId())
+		blackWin, whiteLose = 1, 1
+	case STONE_WHITE:
+		myLogger.Printf("[GAME OVER] Player %s Win!\n", gs.mPlayerWhite.GetPlayerSessionId())
+		whiteWin, blackLose = 1, 1
+	default:
+		myLogger.Print("[GAME OVER] Draw!\n")
+	}
+
+	gs.mWinReason = reason
+	gs.mRated = rated
+	gs.mEloDiff[STONE_BLACK], gs.mEloDiff[STONE_WHITE] = blackNew, whiteNew
+	if reason == WR_FIVE {
+		gs.mWinningLine = gs.FindWinningLine(winner)
+	}
+	myLogger.Printf("[GAME OVER] Reason %s after %d moves\n", reason, gs.mMoveNumber)
+
+	gs.mEndTime = time.Now()
+	gs.mClock.Stop(gs.mEndTime)
+	gs.StopTurnTimer()
+	gs.WriteGameRecord(gs.mGameLiftManager.mRecordPath)
+
+	/// Engine analysis runs in the background so that the game over broadcast isn't delayed.
+	/// Its blunder part is bounded by mAnalysisBudget and the send by SQS_SEND_TIMEOUT, as TerminateGameSession waits for the result to be sent.
+	g := gs.mGameLiftManager
+	black, white := gs.mPlayerBlack, gs.mPlayerWhite
+	moves := append([]MoveRecord(nil), gs.mMoves...)
+
+	g.mPendingResults.Add(1)
+	atomic.AddInt32(&g.mResultBacklog, 1)
+	go func() {
+		defer g.mPendingResults.Done()
+		defer atomic.AddInt32(&g.mResultBacklog, -1)
+
+		var results []string
+
+		/// the suspicion score is there even with the blunder analysis disabled
+		analysis := AnalyzeGame(moves, gs.mConfig.mBoardSize, gs.mConfig.mRule, g.mAnalysisBudget)
+		myLogger.Printf("[ANALYSIS] %d of %d moves analyzed in %s\n", analysis.mAnalyzed, analysis.mTotal, analysis.mElapsed)
+
+		blackAnalysis := analysis.MakeAnalysisJsonString(STONE_BLACK)
+		whiteAnalysis := analysis.MakeAnalysisJsonString(STONE_WHITE)
+
+		/// Bots have no record in the backend
+		if !IsBot(black) {
+			results = append(results, gs.MakeResultJsonString(black.GetPlayerName(), blackNew, blackWin, blackLose, rated, blackAnalysis))
+		}
+		if !IsBot(white) {
+			results = append(results, gs.MakeResultJsonString(white.GetPlayerName(), whiteNew, whiteWin, whiteLose, rated, whiteAnalysis))
+		}
+
+		/// Send to SQS
+		g.SendGameResultToSQS(results...)
+	}()
 }
 
 func (gs *GameSession) IsEnd() bool {
-	return gs.mGameStatus == GS_GAME_OVER_BLACK_WIN || gs.mGameStatus == GS_GAME_OVER_WHITE_WIN
+	return gs.mGameStatus == GS_GAME_OVER_BLACK_WIN || gs.mGameStatus == GS_GAME_OVER_WHITE_WIN || gs.mGameStatus == GS_GAME_OVER_DRAW
 }
@@ -0,0 +1,270 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	myLogger = log.New(io.Discard, "", 0)
+	os.Exit(m.Run())
+}
+
+// testPlayer is a seat that never moves by itself. The test drives it.
+type testPlayer struct {
+	mName         string
+	mCapabilities ClientCapability
+	mPackets      []PacketTypes // received, under the game lock
+	mSizes        []int         // of mPackets
+}
+
+func (tp *testPlayer) GetPlayerSessionId() string            { return "psess-" + tp.mName }
+func (tp *testPlayer) GetPlayerName() string                 { return tp.mName }
+func (tp *testPlayer) GetPlayerScore() int                   { return 1000 }
+func (tp *testPlayer) HasCapability(c ClientCapability) bool { return tp.mCapabilities&c != 0 }
+func (tp *testPlayer) Disconnect(dr DisconnectReason)        {}
+
+func (tp *testPlayer) PostSend(data []byte, len int) bool {
+	tp.mPackets = append(tp.mPackets, PacketTypes(binary.LittleEndian.Uint16(data[2:4])))
+	tp.mSizes = append(tp.mSizes, len)
+	return true
+}
+
+// SizeOf returns the size of the last ptype received, 0 if none
+func (tp *testPlayer) SizeOf(ptype PacketTypes) int {
+	for i := len(tp.mPackets) - 1; i >= 0; i-- {
+		if tp.mPackets[i] == ptype {
+			return tp.mSizes[i]
+		}
+	}
+	return 0
+}
+
+func newTestGameSession(config SessionConfig) *GameSession {
+	g := &GameLiftManager{mMaxPause: time.Minute}
+	gs := &GameSession{
+		mGameStatus:  GS_NOT_STARTED,
+		mCurrentTurn: STONE_NONE,
+		mConfig:      config,
+
+		mColorAssignment: CA_CONNECTION_ORDER,
+		mGameLiftManager: g,
+	}
+	g.mGameSession = gs
+
+	return gs
+}
+
+// startTestGame seats black and white and starts the game like the packet handlers do, under the game lock
+func startTestGame(gs *GameSession, black GamePlayer, white GamePlayer) {
+	gs.mGameLiftManager.mLock.Lock()
+	defer gs.mGameLiftManager.mLock.Unlock()
+
+	gs.PlayerEnter(black)
+	gs.PlayerEnter(white)
+	gs.BroadcastGameStart()
+}
+
+func waitGameOver(t *testing.T, gs *GameSession, timeout time.Duration) GameStatus {
+	t.Helper()
+
+	g := gs.mGameLiftManager
+	for deadline := time.Now().Add(timeout); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		g.mLock.Lock()
+		status, over := gs.mGameStatus, gs.IsEnd()
+		g.mLock.Unlock()
+
+		if over {
+			g.mPendingResults.Wait()
+			return status
+		}
+	}
+
+	t.Fatalf("game not over after %s", timeout)
+	return GS_NOT_STARTED
+}
+
+// The bots think on their own goroutines and the turn timers fire on theirs. Run with -race.
+func TestBotGame(t *testing.T) {
+	config := DefaultSessionConfig()
+	config.mBoardSize = MIN_BOARD_SIZE
+	config.mTimeControl = TimeControl{mMainTime: time.Minute}
+
+	gs := newTestGameSession(config)
+	black := NewBotPlayer(MIN_BOT_LEVEL, gs, 1)
+	white := NewBotPlayer(MIN_BOT_LEVEL, gs, 2)
+
+	startTestGame(gs, black, white)
+	status := waitGameOver(t, gs, time.Minute)
+
+	if status != GS_GAME_OVER_BLACK_WIN && status != GS_GAME_OVER_WHITE_WIN && status != GS_GAME_OVER_DRAW {
+		t.Fatalf("game status %d", status)
+	}
+	if gs.mWinReason != WR_FIVE && gs.mWinReason != WR_DRAW {
+		t.Errorf("win reason %s, want five or draw", gs.mWinReason)
+	}
+	if gs.IsRated() {
+		t.Error("bot game is rated")
+	}
+}
+
+// The timers call TurnTimedOut and PauseTimedOut under the game lock, which is what the test does here with made up times
+// The bot isn't seated once the opponent showed up
+func TestBotTimer(t *testing.T) {
+	gs := newTestGameSession(DefaultSessionConfig())
+	g := gs.mGameLiftManager
+	g.mBotWait = time.Hour
+
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	gs.PlayerEnter(&testPlayer{mName: "black"})
+	g.CheckReadyAll()
+	if g.mBotTimer == nil {
+		t.Fatal("no bot timer while waiting for an opponent")
+	}
+
+	gs.PlayerEnter(&testPlayer{mName: "white"})
+	g.CheckReadyAll()
+	if g.mBotTimer != nil || gs.mGameStatus != GS_STARTED {
+		t.Errorf("bot timer %v, game status %d after the opponent came", g.mBotTimer, gs.mGameStatus)
+	}
+}
+
+func TestTimers(t *testing.T) {
+	const mainTime = time.Minute
+	const maxPause = 10 * time.Second
+
+	tests := []struct {
+		name  string
+		pause bool // black asks for a pause, white accepts, and the pause time runs out before black's time
+	}{
+		{"turn timer", false},
+		{"pause timer then turn timer", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultSessionConfig()
+			config.mTimeControl = TimeControl{mMainTime: mainTime}
+
+			gs := newTestGameSession(config)
+			g := gs.mGameLiftManager
+			g.mMaxPause = maxPause
+			black := &testPlayer{mName: "black"}
+			white := &testPlayer{mName: "white"}
+
+			startTestGame(gs, black, white)
+
+			g.mLock.Lock()
+			defer g.mLock.Unlock()
+			defer gs.StopTurnTimer()
+
+			/// out of time at the start of the turn plus the main time, plus the pause if there was one
+			deadline := gs.mClock.mTurnStartTime.Add(mainTime)
+			if tt.pause {
+				if ec := gs.RequestPause(black); ec != EC_NONE {
+					t.Fatalf("RequestPause error code %d", ec)
+				}
+				if ec := gs.AcceptPause(white); ec != EC_NONE {
+					t.Fatalf("AcceptPause error code %d", ec)
+				}
+
+				pauseEnd := gs.mPauseStart.Add(maxPause)
+				gs.PauseTimedOut(gs.mPauseCount-1, pauseEnd)
+				if gs.mGameStatus != GS_PAUSED {
+					t.Fatalf("game status %d after a stale pause timer, want paused", gs.mGameStatus)
+				}
+				gs.PauseTimedOut(gs.mPauseCount, pauseEnd)
+				if gs.mGameStatus != GS_STARTED {
+					t.Fatalf("game status %d after the pause timer, want started", gs.mGameStatus)
+				}
+				if gs.mPauseUsed[STONE_BLACK] != maxPause {
+					t.Errorf("pause used %s, want %s", gs.mPauseUsed[STONE_BLACK], maxPause)
+				}
+				deadline = deadline.Add(maxPause)
+			}
+
+			/// a timer armed for an earlier move
+			gs.TurnTimedOut(gs.mMoveNumber-1, deadline)
+			gs.TurnTimedOut(gs.mMoveNumber, deadline.Add(-time.Millisecond))
+			if gs.mGameStatus != GS_STARTED {
+				t.Fatalf("game status %d before the deadline, want started", gs.mGameStatus)
+			}
+
+			gs.TurnTimedOut(gs.mMoveNumber, deadline)
+			if gs.mGameStatus != GS_GAME_OVER_WHITE_WIN {
+				t.Fatalf("game status %d, want white win", gs.mGameStatus)
+			}
+			if gs.mWinReason != WR_TIMEOUT {
+				t.Errorf("win reason %s, want timeout", gs.mWinReason)
+			}
+		})
+	}
+}
+
+// Clients without the capabilities keep getting the packets in the layout they were written for
+func TestPacketLayout(t *testing.T) {
+	const startSize = 2 + 2 + MAX_SESSION_LEN + MAX_STRING_LEN
+
+	tests := []struct {
+		name            string
+		capabilities    ClientCapability
+		startSize       int
+		boardStatusSize int
+	}{
+		{"none", 0, startSize, 2 + 2 + BOARD_SIZE*BOARD_SIZE + 1 + 1},
+		{"color assignment", CAP_COLOR_ASSIGNMENT, startSize + 1, 2 + 2 + BOARD_SIZE*BOARD_SIZE + 1 + 1},
+		{"session config", CAP_SESSION_CONFIG, startSize + 10, 2 + 2 + MIN_BOARD_SIZE*MIN_BOARD_SIZE + 1 + 1},
+		{"move number", CAP_MOVE_NUMBER, startSize, 2 + 2 + BOARD_SIZE*BOARD_SIZE + 1 + 1 + 4},
+		{"all", CAP_SUPPORTED, startSize + 11, 2 + 2 + MIN_BOARD_SIZE*MIN_BOARD_SIZE + 1 + 1 + 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultSessionConfig()
+			config.mBoardSize = MIN_BOARD_SIZE
+
+			gs := newTestGameSession(config)
+			black := &testPlayer{mName: "black", mCapabilities: tt.capabilities}
+			white := &testPlayer{mName: "white"}
+
+			startTestGame(gs, black, white)
+			gs.mGameLiftManager.mLock.Lock()
+			gs.SendGameStatus(black)
+			gs.mGameLiftManager.mLock.Unlock()
+
+			if size := black.SizeOf(PKT_SC_START); size != tt.startSize {
+				t.Errorf("PKT_SC_START size %d, want %d", size, tt.startSize)
+			}
+			if size := black.SizeOf(PKT_SC_BOARD_STATUS); size != tt.boardStatusSize {
+				t.Errorf("PKT_SC_BOARD_STATUS size %d, want %d", size, tt.boardStatusSize)
+			}
+			if size := white.SizeOf(PKT_SC_START); size != startSize {
+				t.Errorf("opponent PKT_SC_START size %d, want %d", size, startSize)
+			}
+		})
+	}
+}
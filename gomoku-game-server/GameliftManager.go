@@ -20,21 +20,31 @@ package main
 
 import (
 	"aws/amazon-gamelift-go-sdk/model"
-	"aws/amazon-gamelift-go-sdk/model/request"
 	"aws/amazon-gamelift-go-sdk/server"
 	"context"
 	"fmt"
 	"os"
 	"os/exec"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/gamelift"
 	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
 )
 
 type GameLiftManager struct {
+	mLock    sync.Mutex // serializes the game. Packet handlers, SDK callbacks, bots and timers all take it first
+	mSDKLock sync.Mutex // serializes replacing the SDK connection. Taken before mLock
+
+	mBackend               HostingBackend
+	mServerParameters      server.ServerParameters
+	mProcessParameters     server.ProcessParameters // kept for registering again after reconnecting
+	mAnywhereAuth          *AnywhereAuth            // nil unless connecting to GameLift without the agent
 	mGameSession           *GameSession
 	mPlayerReadyCount      int
 	mCheckTerminationCount int
@@ -42,7 +52,52 @@ type GameLiftManager struct {
 	mRegion                string
 
 	mSQSUrl        string
-	mStateFilename string // for maintaining game session state (IDLE or ACTIVE)
+	mStateFilename string // for maintaining game session state (IDLE or ACTIVE, followed by DRAINING while draining)
+
+	mReuseProcess bool // get ready for the next game session instead of exiting after a game
+	mMaxSessions  int  // game sessions per process before it exits anyway. 0 for no limit
+	mSessionCount int
+
+	mIocpManager     *IocpManager
+	mShutdownTimeout time.Duration // the longest an orderly shutdown may take
+	mShuttingDown    int32         // set by Shutdown
+	mDraining        int32         // set by Drain. No new game sessions
+	mRecordPath      string        // game records are written to mRecordPath + ".sgf" / ".psn", next to the process log
+
+	mHumanSessionCount int           // accepted player sessions. Bots don't count
+	mBotWait           time.Duration // seat a bot when the first player waited this long for an opponent. 0 disables
+	mBotTimer          *time.Timer   // armed while the first player waits for an opponent
+	mBotReplace        bool          // let a bot take over the seat of a player who left mid-game
+	mBotLevel          int
+
+	mAnalysisBudget time.Duration  // post-game blunder analysis time limit. 0 disables
+	mPendingResults sync.WaitGroup // game results not sent yet
+	mResultBacklog  int32          // number of them, for the health check
+	mResultLock     sync.Mutex
+	mUnsentResults  []string // SQS didn't take them. Sent again with the next results and before the process ends
+
+	mMaxUnsentResults int // while SQS is unreachable, the results health probe fails with more unsent results than this
+
+	mSpectatorDelay time.Duration // spectators see the game this much later than the players
+	mSpectatorToken string        // grants spectator access with PKT_CS_SPECTATE. Empty disables
+	mMaxSpectators  int
+	mConnections    int32 // open client connections. Capped in IocpManager.StartAccept
+
+	mChatFilter MessageFilter // nil relays chat as is
+
+	mMaxPause time.Duration // total pause time allowed per player and game
+
+	mReconnectGrace time.Duration // a player who drops mid-game keeps the seat this long. 0 disables
+
+	mColorAssignment ColorAssignment
+	mMatchData       *MatchmakerData // of the current game session. nil if it wasn't created by FlexMatch
+	mGameSessionId   string
+
+	mBackfillTimeout  time.Duration // end the game session if no replacement player is found in time
+	mBackfillLock     sync.Mutex
+	mBackfilling      bool
+	mBackfillTicketId string // empty while FlexMatch backfills automatically
+	mBackfillTimer    *time.Timer
 }
 
 /*
@@ -68,7 +123,7 @@ func (g *GameLiftManager) LoadConfig(ctx context.Context) aws.Config {
 	}
 }
 
-func (g *GameLiftManager) OnStartGameSession(model.GameSession) {
+func (g *GameLiftManager) OnStartGameSession(gameSession model.GameSession) {
 	// When a game session is created,
 	// GameLift sends an activation request to the game server and passes
 	// along the game session object containing game properties and other settings.
@@ -76,12 +131,48 @@ func (g *GameLiftManager) OnStartGameSession(model.GameSession) {
 	// Once the game server is ready to receive incoming player connections,
 	// it should invoke server.ActivateGameSession()
 
-	//FastSpinlockGuard lock(mLock);
-	err := server.ActivateGameSession()
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	myLogger.Println("[GameLift] OnStartGameSession")
+
+	if g.IsShuttingDown() {
+		myLogger.Print("[GAMELIFT] Not activating game session while shutting down: ", gameSession.GameSessionID)
+		return
+	}
+
+	/// GameLift can place the game session elsewhere once we're gone
+	if g.IsDraining() {
+		myLogger.Print("[DRAIN] Not activating game session while draining. Ending process: ", gameSession.GameSessionID)
+		g.TerminateGameSession(0)
+		return
+	}
+
+	/// Don't activate a game session we can't play as asked
+	config, err := ParseSessionConfig(gameSession.GameProperties, gameSession.GameSessionData)
+	if err != nil {
+		g.FailActivation(gameSession.GameSessionID, fmt.Errorf("invalid game session properties: %w", err))
+		return
+	}
+	myLogger.Print("[GAMELIFT] Game session config: ", config.String())
+
+	/// nor one whose players we can't tell from anybody else
+	matchData, err := ParseMatchmakerData(gameSession.MatchmakerData)
+	if err != nil {
+		g.FailActivation(gameSession.GameSessionID, fmt.Errorf("invalid MatchmakerData: %w", err))
+		return
+	}
+
+	err = g.mBackend.ActivateGameSession()
 	if err != nil {
 		myLogger.Fatal(err.Error())
 	}
-	myLogger.Println("[GameLift] OnStartGameSession")
+
+	g.mGameSessionId = gameSession.GameSessionID
+	g.mMatchData = matchData
+	if g.mMatchData != nil {
+		myLogger.Printf("[GAMELIFT] Match %s with %d teams\n", g.mMatchData.MatchId, len(g.mMatchData.Teams))
+	}
 
 	g.mGameSession = &GameSession{
 		mPlayerBlack: nil,
@@ -89,29 +180,35 @@ func (g *GameLiftManager) OnStartGameSession(model.GameSession) {
 		mGameStatus:  GS_NOT_STARTED,
 		mCurrentTurn: STONE_NONE,
 
-		mGameLiftManager: g,
-	}
+		mColorAssignment: g.mColorAssignment,
+		mColorSeed:       time.Now().UnixNano(),
+		mConfig:          config,
 
-	cmd_string := "echo ACTIVE > " + g.mStateFilename
-	cmd := exec.Command("bash", "-c", cmd_string)
-	stdout, err2 := cmd.Output()
-	if err2 != nil {
-		myLogger.Println("Error in writing state file: ", err2)
-	} else {
-		myLogger.Println("State file written: ", string(stdout))
+		mGameLiftManager: g,
 	}
 
-	//mMatchMakerData = g.mGameSession.GetMatchmakerData()
+	g.mSessionCount++
+	g.WriteStateFile("ACTIVE")
 }
 
-func (g *GameLiftManager) OnUpdateGameSession(model.UpdateGameSession) {
+func (g *GameLiftManager) OnUpdateGameSession(update model.UpdateGameSession) {
 	// When a game session is updated (e.g. by FlexMatch backfill),
 	// GameLift sends a request to the game
 	// server containing the updated game session object.
 	// The game server can then examine the provided
 	// MatchmakerData and handle new incoming players appropriately.
 	// UpdateReason is the reason this update is being supplied.
-	myLogger.Print("OnUpdateGameSession")
+	myLogger.Printf("OnUpdateGameSession: %s (ticket %s)\n", update.UpdateReason, update.BackfillTicketID)
+
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	switch update.UpdateReason {
+	case model.MatchmakingDataUpdated:
+		g.MatchmakingDataUpdated(update.GameSession, update.BackfillTicketID)
+	case model.BackfillFailed, model.BackfillTimedOut, model.BackfillCancelled:
+		g.BackfillEnded(update.BackfillTicketID, update.UpdateReason)
+	}
 }
 
 func (g *GameLiftManager) OnProcessTerminate() {
@@ -119,21 +216,41 @@ func (g *GameLiftManager) OnProcessTerminate() {
 
 	// It gives this game server a chance to save its state,
 	// communicate with services, etc., before being shut down.
-	// In this case, we simply tell GameLift we are indeed going to shutdown.
+	// Shutdown lets the current game end, sends the results and then tells GameLift
+	// we are indeed going to shutdown, all before the termination time.
 
 	// game-specific tasks required to gracefully shut down a game session,
 	// such as notifying players, preserving game state data, and other cleanup
-	if g.mActivated {
-		myLogger.Print("[GAMELIFT] OnProcessTerminate Success\n")
-		//g.TerminateGameSession(0xDEAD)
-		g.TerminateGameSession(0)
-	}
+	myLogger.Print("[GAMELIFT] OnProcessTerminate\n")
+
+	/// not on the SDK callback goroutine. Shutdown calls back into the SDK
+	go g.Shutdown("OnProcessTerminate")
+}
+
+// FailActivation ends the process for a game session it can't host, without activating it.
+// GameLift learns from ProcessEnding. That goes out the usual way, after the SDK callback returned.
+func (g *GameLiftManager) FailActivation(gameSessionId string, err error) {
+	myLogger.Print("[GAMELIFT] Not activating game session ", gameSessionId, ". Ending process: ", err)
+
+	go func() {
+		g.mLock.Lock()
+		defer g.mLock.Unlock()
+
+		g.TerminateGameSession(1)
+	}()
 }
 
 func (g *GameLiftManager) TerminateGameSession(exitCode int) {
-	server.ProcessEnding()
+	g.StopBackfill()
+
+	// Let the post-game analysis finish and the results go out first
+	g.mPendingResults.Wait()
+	g.SendGameResultToSQS()
+
+	g.mBackend.ProcessEnding()
 
 	g.mActivated = false
+	g.WriteStateFile("IDLE")
 
 	os.Exit(exitCode)
 }
@@ -144,51 +261,44 @@ func (g *GameLiftManager) OnHealthCheck() bool {
 	// Simply return true if healthy, false otherwise.
 	// The game server has HEALTHCHECK_TIMEOUT interval (60 sec by default) to respond with its health status.
 	// GameLift will default to 'false' if the game server doesn't respond in time.
-	// In this case, we're always healthy!
-	myLogger.Print("OnHealthCheck: ", g.mActivated)
-	return g.mActivated
+	// CheckHealth runs a few probes, each bounded by HEALTH_PROBE_TIMEOUT.
+	healthy, _ := g.CheckHealth()
+	myLogger.Print("OnHealthCheck: ", healthy)
+	return healthy
 }
 
-func (g *GameLiftManager) InitializeGameLift(listenPort int, gameliftEndpoint string, fleetId string, hostId string, logPath string) bool {
+func (g *GameLiftManager) InitializeGameLift(listenPort int, processId string, gameliftEndpoint string, fleetId string, hostId string, authToken string, logPath string) bool {
 	var err error
-/*
-	if (hostId != "") && (fleetId != "") {
-		myLogger.Print("InitializeGameLift in anywhere fleet mode")
-
-		ctx := context.TODO()
-		cfg := g.LoadConfig(ctx)
-		svc := gamelift.NewFromConfig(cfg)
-		output, err := svc.GetComputeAuthToken(ctx,
-			&gamelift.GetComputeAuthTokenInput{
-				ComputeName: aws.String(hostId),
-				FleetId:     aws.String(fleetId),
-			})
 
-		if err != nil {
-			myLogger.Fatal(err.Error())
+	if gameliftEndpoint != "" && fleetId != "" && hostId != "" {
+		// Anywhere fleet mode, connecting to the GameLift endpoint by ourselves
+		myLogger.Print("InitializeGameLift in anywhere fleet mode. Endpoint : ", gameliftEndpoint)
+
+		g.mAnywhereAuth = &AnywhereAuth{mFleetId: fleetId, mHostId: hostId, mToken: authToken}
+		if authToken == "" {
+			ctx := context.TODO()
+			g.mAnywhereAuth.mClient = gamelift.NewFromConfig(g.LoadConfig(ctx))
+			if err = g.mAnywhereAuth.Fetch(ctx); err != nil {
+				myLogger.Fatal("GetComputeAuthToken failed : ", err.Error())
+			}
+			myLogger.Print("Compute auth token expires at ", g.mAnywhereAuth.mExpiration)
 		}
 
-		err = server.InitSDK(server.ServerParameters{
+		g.mServerParameters = server.ServerParameters{
 			WebSocketURL: gameliftEndpoint,
-			ProcessID:    strconv.Itoa(os.Getpid()),
+			ProcessID:    processId,
 			HostID:       hostId,
 			FleetID:      fleetId,
-			AuthToken:    *output.AuthToken,
-		})
+			AuthToken:    g.mAnywhereAuth.mToken,
+		}
 	} else {
-		myLogger.Print("InitializeGameLift in managed fleet mode")
-		serverParameters := server.ServerParameters{"", "", "", "", ""}
-
-		//InitSDK establishes a local connection with GameLift's agent to enable further communication.
-		err = server.InitSDK(serverParameters)
+		// Managed fleet mode or anywhere fleet mode with Amazon GameLift Agent. The SDK takes its parameters from the environment variables.
+		myLogger.Print("InitializeGameLift...")
+		g.mServerParameters = server.ServerParameters{}
 	}
-*/
-	// Initialize GameLift either in managed fleet mode or anywhere fleet mode with Amazon GameLift Agent
-	myLogger.Print("InitializeGameLift...")
-	serverParameters := server.ServerParameters{"", "", "", "", ""}
 
 	//InitSDK establishes a local connection with GameLift's agent to enable further communication.
-	err = server.InitSDK(serverParameters)
+	err = g.mBackend.InitSDK(g.mServerParameters)
 
 	if err != nil {
 		myLogger.Print("InitSDK failed : ", err.Error())
@@ -198,16 +308,24 @@ func (g *GameLiftManager) InitializeGameLift(listenPort int, gameliftEndpoint st
 	// This resets the local connection with GameLift's agent.
 	myLogger.Print("Calling ProcessReady port : ", listenPort)
 
-	err = server.ProcessReady(server.ProcessParameters{
+	g.mRecordPath = strings.TrimSuffix(logPath, ".log")
+
+	g.mProcessParameters = server.ProcessParameters{
 		OnStartGameSession:  g.OnStartGameSession,
 		OnUpdateGameSession: g.OnUpdateGameSession,
 		OnProcessTerminate:  g.OnProcessTerminate,
 		OnHealthCheck:       g.OnHealthCheck,
 		LogParameters: server.LogParameters{
-			LogPaths: []string{logPath},
+			LogPaths: []string{logPath, g.mRecordPath + ".sgf", g.mRecordPath + ".psn"},
 		},
 		Port: listenPort,
-	})
+	}
+	/// IDLE first. A game session may come right after ProcessReady
+	g.mStateFilename = "/tmp/" + strconv.Itoa(listenPort) + ".state"
+	g.WriteStateFile("IDLE")
+
+	g.mLock.Lock()
+	err = g.mBackend.ProcessReady(g.mProcessParameters)
 	if err != nil {
 		myLogger.Print("ProcessReady failed : ", err.Error())
 		myLogger.Fatal(err.Error())
@@ -215,9 +333,21 @@ func (g *GameLiftManager) InitializeGameLift(listenPort int, gameliftEndpoint st
 
 	g.mActivated = true
 	myLogger.Println("ProcessReady... : ", g.mActivated)
+	g.mLock.Unlock()
 
-	g.mStateFilename = "/tmp/" + strconv.Itoa(listenPort) + ".state"
-	cmd_string := "echo IDLE > " + g.mStateFilename
+	go g.KeepSDKConnected()
+
+	return true
+}
+
+// WriteStateFile records the game session state (IDLE or ACTIVE) for the scripts watching this process.
+// While draining, DRAINING follows, e.g. "ACTIVE DRAINING", so that grep ACTIVE still finds active processes.
+func (g *GameLiftManager) WriteStateFile(state string) {
+	if g.IsDraining() {
+		state += " " + STATE_DRAINING
+	}
+
+	cmd_string := "echo " + state + " > " + g.mStateFilename
 	cmd := exec.Command("bash", "-c", cmd_string)
 	stdout, err2 := cmd.Output()
 	if err2 != nil {
@@ -225,91 +355,253 @@ func (g *GameLiftManager) InitializeGameLift(listenPort int, gameliftEndpoint st
 	} else {
 		myLogger.Println("State file written: ", string(stdout))
 	}
-	return true
 }
 
+// SQS_SEND_TIMEOUT bounds sending the game results. The process waits for them before it ends
+const SQS_SEND_TIMEOUT = 3 * time.Second
+
+// SendGameResultToSQS sends results together with those SQS didn't take before.
+// Whatever fails again is kept for the next call. Call without results to only retry.
+func (g *GameLiftManager) SendGameResultToSQS(results ...string) {
+	if g.mSQSUrl == "" {
+		return
+	}
+
+	g.mResultLock.Lock()
+	results = append(g.mUnsentResults, results...)
+	g.mUnsentResults = nil
+	g.mResultLock.Unlock()
+
+	if len(results) == 0 {
+		return
+	}
 
-func (g *GameLiftManager) SendGameResultToSQS(blackJson string, whiteJson string) {
 	// Authenticate and send message to SQS queue
-	ctx := context.TODO()
+	ctx, cancel := context.WithTimeout(context.Background(), SQS_SEND_TIMEOUT)
+	defer cancel()
 	cfg := g.LoadConfig(ctx)
 	svc := sqs.NewFromConfig(cfg)
 
+	// One message per player
+	var entries []types.SendMessageBatchRequestEntry
+	byId := make(map[string]string)
+	for i, result := range results {
+		id := fmt.Sprintf("msg_player_%03d", i+1)
+		byId[id] = result
+		entries = append(entries, types.SendMessageBatchRequestEntry{
+			Id:          aws.String(id),
+			MessageBody: aws.String(result),
+		})
+	}
+
 	sMInput := &sqs.SendMessageBatchInput{
-		Entries: []types.SendMessageBatchRequestEntry{
-			// First message
-			{
-				Id:          aws.String("msg_player_001"),
-				MessageBody: aws.String(blackJson),
-			},
-			{
-				Id:          aws.String("msg_player_002"),
-				MessageBody: aws.String(whiteJson),
-			},
-		},
+		Entries:  entries,
 		QueueUrl: &g.mSQSUrl,
 	}
 
+	var unsent []string
 	resp, err := svc.SendMessageBatch(ctx, sMInput)
 	if err != nil {
-		fmt.Println("Got an error sending the message:")
-		fmt.Println(err)
-		return
+		myLogger.Printf("Got an error sending %d messages: %s\n", len(results), err)
+		unsent = results
+	} else {
+		for _, failed := range resp.Failed {
+			myLogger.Printf("Message %s not sent: %s\n", aws.ToString(failed.Id), aws.ToString(failed.Message))
+			unsent = append(unsent, byId[aws.ToString(failed.Id)])
+		}
+		for _, sent := range resp.Successful {
+			myLogger.Println("Sent message with ID: " + aws.ToString(sent.MessageId))
+		}
 	}
 
-	myLogger.Println("Sent message with ID: " + *resp.Successful[0].MessageId)
+	if len(unsent) > 0 {
+		g.mResultLock.Lock()
+		g.mUnsentResults = append(g.mUnsentResults, unsent...)
+		g.mResultLock.Unlock()
+	}
+}
+
+// UnsentResultCount is the number of game results SQS didn't take so far
+func (g *GameLiftManager) UnsentResultCount() int {
+	g.mResultLock.Lock()
+	defer g.mResultLock.Unlock()
+
+	return len(g.mUnsentResults)
 }
 
 func (g *GameLiftManager) FinalizeGameLift() {
-	server.Destroy()
+	g.mBackend.Destroy()
 }
 
 func (g *GameLiftManager) AcceptPlayerSession(psess *PlayerSession, playerSessionId string) bool {
 	//FastSpinlockGuard lock(mLock);
 
-	err := server.AcceptPlayerSession(playerSessionId)
+	if g.mGameSession == nil {
+		myLogger.Print("[GAMELIFT] AcceptPlayerSession Denied. No game session: ", playerSessionId)
+		return false
+	}
+
+	/// One call for the role, the match and the name of the player
+	playerSession, err := g.DescribePlayerSessions(playerSessionId)
+	if err != nil {
+		return false
+	}
+	psess.mPlayerName = playerSession.PlayerID
+
+	if IsSpectatorPlayerData(playerSession.PlayerData) {
+		return g.AcceptSpectatorSession(psess, playerSessionId)
+	}
+
+	if g.mGameSession.mGameStatus != GS_NOT_STARTED {
+		myLogger.Print("[GAMELIFT] AcceptPlayerSession Denied. No free seat: ", playerSessionId)
+		return false
+	}
+
+	if !g.SetMatchPlayer(psess, playerSession) {
+		return false
+	}
+
+	err = g.mBackend.AcceptPlayerSession(playerSessionId)
 	if err != nil {
 		myLogger.Print("[GAMELIFT] AcceptPlayerSession Fail: \n", err.Error())
 		return false
 	} else {
+		g.mHumanSessionCount++
 		g.mGameSession.PlayerEnter(psess)
 		return true
 	}
 }
 
-func (g *GameLiftManager) RemovePlayerSession(psess *PlayerSession, playerSessionId string) {
+// SetMatchPlayer takes the rating and team of the player from the matchmaker data.
+// Players who aren't part of the match are denied.
+func (g *GameLiftManager) SetMatchPlayer(psess *PlayerSession, playerSession *model.PlayerSession) bool {
+	if g.mMatchData == nil {
+		return true
+	}
+
+	player, team := g.mMatchData.FindPlayer(playerSession.PlayerID)
+	if player == nil {
+		myLogger.Printf("[GAMELIFT] AcceptPlayerSession Denied. %s isn't part of match %s: %s\n", playerSession.PlayerID, g.mMatchData.MatchId, playerSession.PlayerSessionID)
+		return false
+	}
+
+	psess.mTeam = team
+	if score, ok := player.NumberAttribute(MATCH_SCORE_ATTRIBUTE); ok {
+		psess.mScore = int(score)
+	}
+
+	myLogger.Printf("[GAMELIFT] %s plays for team %s with score %d\n", playerSession.PlayerID, psess.mTeam, psess.mScore)
+	return true
+}
+
+func (g *GameLiftManager) AcceptSpectatorSession(psess *PlayerSession, playerSessionId string) bool {
+	if psess.mSpectator {
+		myLogger.Print("[GAMELIFT] AcceptPlayerSession Denied. Already spectating: ", playerSessionId)
+		return false
+	}
+
+	if g.mGameSession.SpectatorCount() >= g.mMaxSpectators {
+		myLogger.Print("[GAMELIFT] AcceptPlayerSession Denied. Too many spectators: ", playerSessionId)
+		return false
+	}
+
+	err := g.mBackend.AcceptPlayerSession(playerSessionId)
+	if err != nil {
+		myLogger.Print("[GAMELIFT] AcceptPlayerSession Fail: \n", err.Error())
+		return false
+	}
+
+	psess.mSpectator = true
+	g.mGameSession.SpectatorEnter(psess)
+	return true
+}
+
+// AcceptSpectatorToken lets a client without a GameLift player session watch the game
+func (g *GameLiftManager) AcceptSpectatorToken(psess *PlayerSession, token string) ErrorCode {
+	if g.mGameSession == nil {
+		return EC_GAME_NOT_STARTED
+	}
+
+	if g.IsShuttingDown() {
+		return EC_SHUTTING_DOWN
+	}
+
+	if psess.IsValid() || psess.mSpectator || !CheckSpectatorToken(g.mSpectatorToken, token) {
+		myLogger.Print("[SPECTATOR] Spectator token denied: ", psess.mClientAddr.String())
+		return EC_UNAUTHENTICATED
+	}
+
+	if g.mGameSession.SpectatorCount() >= g.mMaxSpectators {
+		myLogger.Print("[SPECTATOR] Too many spectators: ", psess.mClientAddr.String())
+		return EC_SPECTATORS_FULL
+	}
+
+	psess.mSpectator = true
+	psess.mPlayerName = SPECTATOR_ROLE
+	g.mGameSession.SpectatorEnter(psess)
+	return EC_NONE
+}
+
+// RemoveSpectator doesn't count toward game session termination. Only players do
+func (g *GameLiftManager) RemoveSpectator(psess *PlayerSession) {
+	if !psess.mSpectator {
+		return
+	}
+	psess.mSpectator = false
+
+	if psess.IsValid() {
+		err := g.mBackend.RemovePlayerSession(psess.mPlayerSessionId)
+		if err != nil {
+			myLogger.Print("[GAMELIFT] RemovePlayerSession Fail: ", err.Error())
+		}
+	}
+
+	if g.mGameSession != nil {
+		g.mGameSession.SpectatorLeave(psess)
+	}
+}
+
+// RemovePlayerSession takes the player out of the game. reason is what a mid-game leave counts as.
+func (g *GameLiftManager) RemovePlayerSession(psess *PlayerSession, playerSessionId string, reason WinReason) {
 	//FastSpinlockGuard lock(mLock);
 
+	if psess.mSpectator {
+		g.RemoveSpectator(psess)
+		return
+	}
+
 	myLogger.Print("RemovePlayerSession : ", psess, playerSessionId)
-	err := server.RemovePlayerSession(playerSessionId)
+	err := g.mBackend.RemovePlayerSession(playerSessionId)
 	if err != nil {
 		myLogger.Print("[GAMELIFT] RemovePlayerSession Fail: ", err.Error())
 	} else {
-		g.mGameSession.PlayerLeave(psess)
+		g.mGameSession.PlayerLeave(psess, reason)
+
+		/// a matched player left before the game started. Find another one
+		if g.mGameSession.mGameStatus == GS_NOT_STARTED && g.mMatchData != nil && !g.IsShuttingDown() {
+			g.StartBackfill(psess.mPlayerName)
+		}
 	}
 
 	g.mCheckTerminationCount = g.mCheckTerminationCount + 1
-	if g.mCheckTerminationCount < MAX_PLAYER_PER_GAME {
+	if g.mCheckTerminationCount < g.mHumanSessionCount {
 		return
 	}
 
 	if g.mGameSession.IsEnd() && g.mActivated {
 		myLogger.Print("[GAMELIFT] Terminate GameSession\n")
-		g.TerminateGameSession(0)
+		g.EndGameSession()
 	}
 }
 
 func (g *GameLiftManager) DescribePlayerSessions(playerSessionId string) (*model.PlayerSession, error) {
-	describePlayerSessionsRequest := request.NewDescribePlayerSessions()
-	describePlayerSessionsRequest.PlayerSessionID = playerSessionId
-
-	describePlayerSessionsResponse, err := server.DescribePlayerSessions(describePlayerSessionsRequest)
+	playerSession, err := g.mBackend.DescribePlayerSession(playerSessionId)
 	if err != nil {
 		myLogger.Print("[GAMELIFT] DescribePlayerSessions Failed: ", err.Error())
 		return nil, err
 	}
 
-	return &describePlayerSessionsResponse.PlayerSessions[0], nil
+	return playerSession, nil
 }
 
 func (g *GameLiftManager) CheckReadyAll() {
@@ -318,33 +610,53 @@ func (g *GameLiftManager) CheckReadyAll() {
 	//		return;
 
 	g.mPlayerReadyCount = g.mPlayerReadyCount + 1
+	if g.mPlayerReadyCount == 1 && g.mBotWait > 0 {
+		g.StopBotTimer()
+
+		gs := g.mGameSession
+		g.mBotTimer = time.AfterFunc(g.mBotWait, func() {
+			g.mLock.Lock()
+			defer g.mLock.Unlock()
+
+			/// not for the next game session of a reused process
+			if g.mGameSession == gs {
+				g.SeatBot()
+			}
+		})
+	}
+
 	if g.mPlayerReadyCount != MAX_PLAYER_PER_GAME {
 		return
 	}
 
+	g.StopBotTimer()
+	g.StopBackfill()
 	g.mGameSession.BroadcastGameStart()
 }
 
-func (g *GameLiftManager) SetStateFilename(filename string) {
-	g.mStateFilename = filename
+// SeatBot gives the waiting player a bot opponent when nobody else showed up
+func (g *GameLiftManager) SeatBot() {
+	gs := g.mGameSession
+
+	if gs == nil || gs.mGameStatus != GS_NOT_STARTED || g.mPlayerReadyCount != 1 || g.mCheckTerminationCount > 0 {
+		myLogger.Printf("[BOT] Not seating a bot. %d players ready, %d left\n", g.mPlayerReadyCount, g.mCheckTerminationCount)
+		return
+	}
+
+	bot := NewBotPlayer(g.mBotLevel, gs, time.Now().UnixNano())
+	myLogger.Printf("[BOT] No opponent after %s. Seating %s\n", g.mBotWait, bot.GetPlayerName())
+
+	gs.PlayerEnter(bot)
+	g.CheckReadyAll()
 }
 
-/*
-func (g *GameLiftManager) FindScoreFromMatchData(string playerName) int {
-	std::string err;
-	const auto json = Json::parse(mMatchMakerData, err);
-
-	for (auto& team : json["teams"].array_items())
-	{
-		for (auto& player : team["players"].array_items())
-		{
-			if (player["playerId"].string_value() == playerName)
-			{
-				return player["attributes"]["score"]["valueAttribute"].int_value();
-			}
-		}
+func (g *GameLiftManager) StopBotTimer() {
+	if g.mBotTimer != nil {
+		g.mBotTimer.Stop()
+		g.mBotTimer = nil
 	}
+}
 
-	return 0;
+func (g *GameLiftManager) SetStateFilename(filename string) {
+	g.mStateFilename = filename
 }
-*/
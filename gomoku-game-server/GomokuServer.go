@@ -24,6 +24,7 @@ import (
 	"github.com/google/uuid"
 	"log"
 	"os"
+	"time"
 )
 
 var GGameLiftManager *GameLiftManager
@@ -39,8 +40,19 @@ func GetOrMakeProcessId() string {
 }
 
 func main() {
-	var port int
-	var gamelift_endpoint, fleet_id, host_id, sqs_url, region string
+	var port, bot_wait, bot_level, spectator_delay, max_spectators, reconnect_grace, backfill_timeout, max_sessions, max_unsent_results int
+	var bot_replace, reuse_process bool
+	var analysis_budget, max_pause, shutdown_timeout time.Duration
+	var gamelift_endpoint, fleet_id, host_id, auth_token, sqs_url, region, spectator_token, chat_filter, color_assignment, backend, player_sessions, standalone_http, admin_addr string
+	var standalone_start bool
+
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(RunReplay(os.Args[2:]))
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "arena" {
+		os.Exit(RunArena(os.Args[2:]))
+	}
 
 	processId := GetOrMakeProcessId()
 	logFilePath := fmt.Sprintf("logs/%s.log", processId)
@@ -72,8 +84,40 @@ func main() {
 	flag.StringVar(&gamelift_endpoint, "endpoint", "", "gamelift endpoint URL")
 	flag.StringVar(&fleet_id, "fleet-id", "", "fleet Id")
 	flag.StringVar(&host_id, "host-id", "", "host id")
+	flag.StringVar(&auth_token, "auth-token", os.Getenv("GAMELIFT_SDK_AUTH_TOKEN"), "compute auth token for --endpoint. Fetched with GetComputeAuthToken and refreshed before expiry if empty")
 	flag.StringVar(&sqs_url, "sqs-url", "", "sqs url")
 	flag.StringVar(&region, "region", "", "region")
+	flag.IntVar(&max_unsent_results, "max-unsent-results", 0, "game results SQS may fail to take before the health check fails, while SQS is unreachable")
+	flag.IntVar(&bot_wait, "bot-wait", 0, "seconds a player waits for an opponent before a bot takes the seat. 0 disables")
+	flag.BoolVar(&bot_replace, "bot-replace", false, "let a bot take over for a player who leaves mid-game")
+	flag.IntVar(&bot_level, "bot-level", 3, fmt.Sprintf("bot difficulty level (%d-%d)", MIN_BOT_LEVEL, MAX_BOT_LEVEL))
+	flag.DurationVar(&analysis_budget, "analysis-budget", 3*time.Second, "time limit of the post-game blunder analysis attached to game results. 0 disables. The suspicion score is computed anyway")
+
+	flag.IntVar(&spectator_delay, "spectator-delay", 10, "seconds spectators lag behind the players")
+	flag.StringVar(&spectator_token, "spectator-token", "", "token for joining as spectator without a GameLift player session. Empty disables")
+	flag.IntVar(&max_spectators, "max-spectators", 16, "maximum number of spectators")
+
+	flag.StringVar(&chat_filter, "chat-filter", "", "word list file for masking chat messages, one word per line")
+
+	flag.DurationVar(&max_pause, "max-pause", 5*time.Minute, "total pause time allowed per player. The game resumes by itself when it runs out")
+
+	flag.IntVar(&reconnect_grace, "reconnect-grace", 30, "seconds a player who drops mid-game may take to reconnect before forfeiting. 0 disables")
+
+	flag.StringVar(&color_assignment, "color-assignment", "connection", "how players get black and white: connection, random, team, rating or alternate")
+
+	flag.IntVar(&backfill_timeout, "backfill-timeout", 60, "seconds to wait for FlexMatch to replace a player who left before the game started")
+
+	flag.BoolVar(&reuse_process, "reuse-process", false, "host the next game session in the same process instead of exiting after a game")
+	flag.IntVar(&max_sessions, "max-sessions", 0, "with --reuse-process, exit after this many game sessions. 0 for no limit")
+
+	flag.StringVar(&admin_addr, "admin-addr", "", "local address for POST /drain and GET /healthz, e.g. 127.0.0.1:9100. Empty disables")
+
+	flag.DurationVar(&shutdown_timeout, "shutdown-timeout", 25*time.Second, "the longest the shutdown on SIGTERM or OnProcessTerminate may take. Keep it below the stop timeout of the container")
+
+	flag.StringVar(&backend, "backend", "gamelift", "hosting backend: gamelift, or standalone to run without GameLift")
+	flag.StringVar(&player_sessions, "player-sessions", "", "standalone backend: file of allowed player session IDs. Any ID is accepted if empty")
+	flag.BoolVar(&standalone_start, "standalone-start", true, "standalone backend: start a game session right after startup")
+	flag.StringVar(&standalone_http, "standalone-http", "", "standalone backend: address for POST /game-session to start a game session, e.g. :8080")
 
 	flag.Parse()
 
@@ -89,20 +133,80 @@ func main() {
 		mGameSession:           nil,
 		mRegion:                region,
 		mSQSUrl:                sqs_url,
+
+		mBotWait:    time.Duration(bot_wait) * time.Second,
+		mBotReplace: bot_replace,
+		mBotLevel:   bot_level,
+
+		mAnalysisBudget: analysis_budget,
+
+		mMaxUnsentResults: max_unsent_results,
+
+		mSpectatorDelay: time.Duration(spectator_delay) * time.Second,
+		mSpectatorToken: spectator_token,
+		mMaxSpectators:  max_spectators,
+
+		mMaxPause: max_pause,
+
+		mReconnectGrace: time.Duration(reconnect_grace) * time.Second,
+
+		mBackfillTimeout: time.Duration(backfill_timeout) * time.Second,
+
+		mReuseProcess: reuse_process,
+		mMaxSessions:  max_sessions,
+
+		mShutdownTimeout: shutdown_timeout,
+	}
+
+	GGameLiftManager.mBackend, err = NewHostingBackend(backend, player_sessions, standalone_start, standalone_http)
+	if err != nil {
+		myLogger.Fatal(err)
+	}
+
+	/// A reused process registers again under a new process ID. Only a process connecting to GameLift by itself can
+	if reuse_process && backend == "gamelift" && (gamelift_endpoint == "" || fleet_id == "" || host_id == "") {
+		myLogger.Fatal("--reuse-process needs --endpoint, --fleet-id and --host-id. Managed fleets and the GameLift agent fix the process ID")
+	}
+
+	GGameLiftManager.mColorAssignment, err = ParseColorAssignment(color_assignment)
+	if err != nil {
+		myLogger.Fatal(err)
 	}
 
-	GGameLiftManager.InitializeGameLift(port, gamelift_endpoint, fleet_id, host_id, logFilePath)
+	if chat_filter != "" {
+		filter, err := LoadWordListFilter(chat_filter)
+		if err != nil {
+			myLogger.Fatal("Loading chat filter failed: ", err)
+		}
+		GGameLiftManager.mChatFilter = filter
+	} else {
+		GGameLiftManager.mChatFilter = NewWordListFilter(nil)
+	}
 
+	// Listen before ProcessReady, so that players are never sent to a closed port
 	GIocpManager := IocpManager{}
 
 	if false == GIocpManager.Initialize(port) {
 		return
 	}
+	GGameLiftManager.mIocpManager = &GIocpManager
+
+	GGameLiftManager.InitializeGameLift(port, processId, gamelift_endpoint, fleet_id, host_id, auth_token, logFilePath)
+	GGameLiftManager.HandleSignals()
+
+	if admin_addr != "" {
+		GGameLiftManager.StartAdminServer(admin_addr)
+	}
 
 	GIocpManager.StartIoThreads()
 
 	GIocpManager.StartAccept(GGameLiftManager)
 
+	/// Shutdown closed the listener and ends the process when it's done
+	if GGameLiftManager.IsShuttingDown() {
+		select {}
+	}
+
 	GGameLiftManager.FinalizeGameLift()
 	myLogger.Print("Exiting game server process")
 }
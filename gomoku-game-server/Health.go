@@ -0,0 +1,202 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	HEALTH_PROBE_TIMEOUT = 2 * time.Second // for each probe
+)
+
+// HealthProbe is the outcome of one check of CheckHealth, as served on /healthz
+type HealthProbe struct {
+	Name    string
+	Healthy bool
+	Detail  string
+}
+
+// CheckHealth runs every probe and logs its outcome. The process is healthy if all probes are.
+func (g *GameLiftManager) CheckHealth() (bool, []HealthProbe) {
+	checks := []struct {
+		name  string
+		probe func() (string, error)
+	}{
+		{"process", g.ProbeProcess},
+		{"listener", g.ProbeListener},
+		{"game", g.ProbeGameLoop},
+		{"results", g.ProbeResultSink},
+		{"statefile", g.ProbeStateFile},
+	}
+
+	healthy := true
+	probes := make([]HealthProbe, 0, len(checks))
+	for _, c := range checks {
+		detail, err := c.probe()
+		if err != nil {
+			detail = err.Error()
+			healthy = false
+		}
+		probes = append(probes, HealthProbe{Name: c.name, Healthy: err == nil, Detail: detail})
+		myLogger.Printf("[HEALTH] %s healthy=%t: %s\n", c.name, err == nil, detail)
+	}
+
+	return healthy, probes
+}
+
+// WithGameLock runs f under the game lock, like a packet handler would. It fails if the lock isn't free
+// within HEALTH_PROBE_TIMEOUT. f still runs once it is, so it should only read.
+func (g *GameLiftManager) WithGameLock(f func()) error {
+	done := make(chan struct{})
+
+	go func() {
+		g.mLock.Lock()
+		defer g.mLock.Unlock()
+
+		f()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(HEALTH_PROBE_TIMEOUT):
+		return fmt.Errorf("game lock still held after %s", HEALTH_PROBE_TIMEOUT)
+	}
+}
+
+// ProbeProcess fails before ProcessReady and while draining without a game session to finish
+func (g *GameLiftManager) ProbeProcess() (string, error) {
+	var activated, hasGameSession bool
+	if err := g.WithGameLock(func() { activated, hasGameSession = g.mActivated, g.mGameSession != nil }); err != nil {
+		return "", err
+	}
+
+	if !activated {
+		return "", errors.New("not ready for game sessions")
+	}
+	if g.IsDraining() && !hasGameSession {
+		return "", errors.New("draining without a game session")
+	}
+	if g.IsDraining() {
+		return "draining", nil
+	}
+	return "ready", nil
+}
+
+// ProbeListener checks that the accept loop runs. It doesn't connect, as a probe connection
+// would take one of the capped connections and be dropped for not logging in.
+// A full server is still healthy.
+func (g *GameLiftManager) ProbeListener() (string, error) {
+	if g.mIocpManager == nil || atomic.LoadInt32(&g.mIocpManager.mAccepting) == 0 {
+		return "", errors.New("not accepting connections")
+	}
+
+	return fmt.Sprintf("accepting on port %d, %d of %d connections open", g.mIocpManager.mListenPort, atomic.LoadInt32(&g.mConnections), g.MaxConnections()), nil
+}
+
+// ProbeGameLoop waits for its turn at the game like a packet, bot or timer would, and then
+// for the spectator feeds. It fails if something is stuck holding one of the locks.
+func (g *GameLiftManager) ProbeGameLoop() (string, error) {
+	detail := "no game session"
+
+	err := g.WithGameLock(func() {
+		g.mBackfillLock.Lock()
+		g.mBackfillLock.Unlock()
+
+		if gs := g.mGameSession; gs != nil {
+			gs.mSpectatorLock.Lock()
+			gs.mSpectatorLock.Unlock()
+
+			detail = fmt.Sprintf("game status %d after %d moves", gs.mGameStatus, gs.mMoveNumber)
+		}
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return detail, nil
+}
+
+// ProbeResultSink passes if SQS is reachable, or if it failed to take no more than mMaxUnsentResults game results
+func (g *GameLiftManager) ProbeResultSink() (string, error) {
+	inFlight := atomic.LoadInt32(&g.mResultBacklog)
+	unsent := g.UnsentResultCount()
+
+	if g.mSQSUrl == "" {
+		return fmt.Sprintf("no SQS queue, %d games being analyzed", inFlight), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), HEALTH_PROBE_TIMEOUT)
+	defer cancel()
+
+	svc := sqs.NewFromConfig(g.LoadConfig(ctx))
+	resp, err := svc.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       &g.mSQSUrl,
+		AttributeNames: []types.QueueAttributeName{types.QueueAttributeNameApproximateNumberOfMessages},
+	})
+	if err == nil {
+		return fmt.Sprintf("SQS reachable with %s messages queued, %d games being sent, %d results to send again", resp.Attributes[string(types.QueueAttributeNameApproximateNumberOfMessages)], inFlight, unsent), nil
+	}
+
+	if unsent <= g.mMaxUnsentResults {
+		return fmt.Sprintf("SQS unreachable (%s), %d games being sent, %d results to send again", err, inFlight, unsent), nil
+	}
+	return "", fmt.Errorf("SQS unreachable (%w), %d results not sent", err, unsent)
+}
+
+// ProbeStateFile opens the state file for writing without changing it
+func (g *GameLiftManager) ProbeStateFile() (string, error) {
+	if g.mStateFilename == "" {
+		return "", errors.New("no state file yet")
+	}
+
+	f, err := os.OpenFile(g.mStateFilename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+
+	return g.mStateFilename + " writable", nil
+}
+
+// HandleHealthz answers 200 if healthy and 503 if not, with the probes as JSON
+func (g *GameLiftManager) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	healthy, probes := g.CheckHealth()
+
+	w.Header().Set("Content-Type", "application/json")
+	if !healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(struct {
+		Healthy bool
+		Probes  []HealthProbe
+	}{healthy, probes})
+}
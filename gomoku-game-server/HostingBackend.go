@@ -0,0 +1,432 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"aws/amazon-gamelift-go-sdk/model"
+	"aws/amazon-gamelift-go-sdk/model/request"
+	"aws/amazon-gamelift-go-sdk/server"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// HostingBackend is what GameLiftManager needs from the service hosting the game server process.
+// GameLiftBackend goes to GameLift through the server SDK. StandaloneBackend runs without it,
+// for local development and automated tests.
+type HostingBackend interface {
+	InitSDK(params server.ServerParameters) error
+	ProcessReady(params server.ProcessParameters) error
+	ProcessEnding() error
+	ActivateGameSession() error
+	AcceptPlayerSession(playerSessionId string) error
+	RemovePlayerSession(playerSessionId string) error
+	DescribePlayerSession(playerSessionId string) (*model.PlayerSession, error)
+	StartMatchBackfill(req request.StartMatchBackfillRequest) (string, error) // returns the ticket ID
+	StopMatchBackfill(req request.StopMatchBackfillRequest) error
+	GetTerminationTime() (time.Time, error)
+	Destroy() error
+	Disconnected() <-chan error // gets an error when an SDK call finds the connection to GameLift lost. nil if it can't happen
+}
+
+func NewHostingBackend(name string, allowlistPath string, autoStart bool, httpAddr string) (HostingBackend, error) {
+	switch name {
+	case "gamelift":
+		return &GameLiftBackend{mLost: make(chan error, 1)}, nil
+
+	case "standalone":
+		b := &StandaloneBackend{
+			mAutoStart: autoStart,
+			mHttpAddr:  httpAddr,
+			mAccepted:  make(map[string]bool),
+		}
+		if allowlistPath != "" {
+			if err := b.LoadAllowlist(allowlistPath); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	}
+
+	return nil, fmt.Errorf("unknown backend %q. Either gamelift or standalone", name)
+}
+
+type GameLiftBackend struct {
+	mLost chan error
+}
+
+// IsConnectionError tells a lost connection from an error answer of the service, which is no reason to reconnect
+func IsConnectionError(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) || errors.As(err, &netErr)
+}
+
+// check reports err on mLost if the connection is gone. Only the first one is kept until it's taken
+func (b *GameLiftBackend) check(err error) error {
+	if err != nil && IsConnectionError(err) {
+		select {
+		case b.mLost <- err:
+		default:
+		}
+	}
+	return err
+}
+
+func (b *GameLiftBackend) InitSDK(params server.ServerParameters) error {
+	return server.InitSDK(params)
+}
+
+func (b *GameLiftBackend) ProcessReady(params server.ProcessParameters) error {
+	return server.ProcessReady(params)
+}
+
+func (b *GameLiftBackend) ProcessEnding() error {
+	return b.check(server.ProcessEnding())
+}
+
+func (b *GameLiftBackend) ActivateGameSession() error {
+	return b.check(server.ActivateGameSession())
+}
+
+func (b *GameLiftBackend) AcceptPlayerSession(playerSessionId string) error {
+	return b.check(server.AcceptPlayerSession(playerSessionId))
+}
+
+func (b *GameLiftBackend) RemovePlayerSession(playerSessionId string) error {
+	return b.check(server.RemovePlayerSession(playerSessionId))
+}
+
+func (b *GameLiftBackend) DescribePlayerSession(playerSessionId string) (*model.PlayerSession, error) {
+	describePlayerSessionsRequest := request.NewDescribePlayerSessions()
+	describePlayerSessionsRequest.PlayerSessionID = playerSessionId
+
+	describePlayerSessionsResponse, err := server.DescribePlayerSessions(describePlayerSessionsRequest)
+	if err != nil {
+		return nil, b.check(err)
+	}
+	if len(describePlayerSessionsResponse.PlayerSessions) == 0 {
+		return nil, errors.New("no such player session: " + playerSessionId)
+	}
+
+	return &describePlayerSessionsResponse.PlayerSessions[0], nil
+}
+
+func (b *GameLiftBackend) StartMatchBackfill(req request.StartMatchBackfillRequest) (string, error) {
+	result, err := server.StartMatchBackfill(req)
+	if err != nil {
+		return "", err
+	}
+	return result.TicketID, nil
+}
+
+func (b *GameLiftBackend) StopMatchBackfill(req request.StopMatchBackfillRequest) error {
+	return server.StopMatchBackfill(req)
+}
+
+// GetTerminationTime is set once GameLift asked the process to terminate
+func (b *GameLiftBackend) GetTerminationTime() (time.Time, error) {
+	terminationTime, err := server.GetTerminationTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	if terminationTime <= 0 {
+		return time.Time{}, errors.New("no termination time set")
+	}
+	return EpochTime(terminationTime), nil
+}
+
+// DOTNET_EPOCH_TICKS is 1970-01-01 in 100 ns ticks since 0001-01-01
+const DOTNET_EPOCH_TICKS = 621355968000000000
+
+// EpochTime reads a timestamp whose unit isn't pinned down. gamelift-local sends the termination time
+// in milliseconds, and the SDK documentation has described it in other units, so the unit is taken
+// from the magnitude: seconds, milliseconds, microseconds, .NET ticks or nanoseconds.
+func EpochTime(v int64) time.Time {
+	switch {
+	case v < 1e11:
+		return time.Unix(v, 0)
+	case v < 1e14:
+		return time.UnixMilli(v)
+	case v < 1e17:
+		return time.UnixMicro(v)
+	case v < 1e18:
+		return time.Unix(0, (v-DOTNET_EPOCH_TICKS)*100)
+	default:
+		return time.Unix(0, v)
+	}
+}
+
+func (b *GameLiftBackend) Destroy() error {
+	return server.Destroy()
+}
+
+func (b *GameLiftBackend) Disconnected() <-chan error {
+	return b.mLost
+}
+
+// StandalonePlayer is an allowlist entry
+type StandalonePlayer struct {
+	mPlayerId   string
+	mPlayerData string
+}
+
+// StandaloneBackend hosts one game session at a time without GameLift.
+// It starts it right after ProcessReady or on POST /game-session, and accepts any player session ID
+// unless an allowlist is given.
+type StandaloneBackend struct {
+	mLock          sync.Mutex
+	mParams        server.ProcessParameters
+	mAutoStart     bool
+	mHttpAddr      string
+	mHttpServer    *http.Server
+	mAllowlist     map[string]StandalonePlayer // nil accepts any player session ID
+	mAccepted      map[string]bool
+	mSessionCount  int
+	mSessionActive bool
+}
+
+// LoadAllowlist reads lines of "playerSessionId [playerId [playerData]]". '#' starts a comment line.
+func (b *StandaloneBackend) LoadAllowlist(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b.mAllowlist = make(map[string]StandalonePlayer)
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		var player StandalonePlayer
+		if len(fields) > 1 {
+			player.mPlayerId = fields[1]
+		}
+		if len(fields) > 2 {
+			player.mPlayerData = fields[2]
+		}
+		b.mAllowlist[fields[0]] = player
+	}
+
+	return scanner.Err()
+}
+
+func (b *StandaloneBackend) InitSDK(params server.ServerParameters) error {
+	myLogger.Print("[STANDALONE] InitSDK. Not connecting to GameLift")
+	return nil
+}
+
+func (b *StandaloneBackend) ProcessReady(params server.ProcessParameters) error {
+	b.mParams = params
+
+	/// A reused process registers again after Destroy closed the HTTP server
+	if b.mHttpAddr != "" && b.mHttpServer == nil {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/game-session", b.HandleGameSession)
+		httpServer := &http.Server{Addr: b.mHttpAddr, Handler: mux}
+		b.mHttpServer = httpServer
+
+		go func() {
+			myLogger.Print("[STANDALONE] Listening for game session requests on ", b.mHttpAddr)
+			if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				myLogger.Print("[STANDALONE] HTTP server failed: ", err)
+			}
+		}()
+	}
+
+	if b.mAutoStart {
+		go b.StartGameSession(nil, "", "")
+	}
+
+	return nil
+}
+
+// StartGameSession plays the part of GameLift placing a game session on this process
+func (b *StandaloneBackend) StartGameSession(gameProperties map[string]string, gameSessionData string, matchmakerData string) error {
+	b.mLock.Lock()
+	if b.mSessionActive {
+		b.mLock.Unlock()
+		return errors.New("a game session is already running")
+	}
+	b.mSessionActive = true
+	b.mSessionCount++
+	gameSession := model.GameSession{
+		GameSessionID:             fmt.Sprintf("standalone-%d-%d", os.Getpid(), b.mSessionCount),
+		Name:                      "standalone",
+		FleetID:                   "standalone",
+		MaximumPlayerSessionCount: MAX_PLAYER_PER_GAME,
+		GameProperties:            gameProperties,
+		GameSessionData:           gameSessionData,
+		MatchmakerData:            matchmakerData,
+	}
+	b.mLock.Unlock()
+
+	myLogger.Print("[STANDALONE] Starting game session ", gameSession.GameSessionID)
+	b.mParams.OnStartGameSession(gameSession)
+	return nil
+}
+
+// HandleGameSession starts a game session on POST, optionally with a JSON body of
+// { "GameProperties" : { "key" : "value" }, "GameSessionData" : "...", "MatchmakerData" : "..." }.
+// Properties or matchmaker data the game server would reject are refused here, so the process doesn't end over a bad request.
+func (b *StandaloneBackend) HandleGameSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST to start a game session", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var body struct {
+		GameProperties  map[string]string
+		GameSessionData string
+		MatchmakerData  string
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if _, err := ParseSessionConfig(body.GameProperties, body.GameSessionData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := ParseMatchmakerData(body.MatchmakerData); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := b.StartGameSession(body.GameProperties, body.GameSessionData, body.MatchmakerData); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (b *StandaloneBackend) ProcessEnding() error {
+	myLogger.Print("[STANDALONE] ProcessEnding")
+
+	b.mLock.Lock()
+	b.mSessionActive = false
+	b.mLock.Unlock()
+	return nil
+}
+
+func (b *StandaloneBackend) ActivateGameSession() error {
+	myLogger.Print("[STANDALONE] ActivateGameSession")
+	return nil
+}
+
+func (b *StandaloneBackend) AcceptPlayerSession(playerSessionId string) error {
+	b.mLock.Lock()
+	defer b.mLock.Unlock()
+
+	if !b.mSessionActive {
+		return errors.New("no active game session")
+	}
+	if b.mAllowlist != nil {
+		if _, ok := b.mAllowlist[playerSessionId]; !ok {
+			return errors.New("player session not in allowlist: " + playerSessionId)
+		}
+	}
+	if b.mAccepted[playerSessionId] {
+		return errors.New("player session already accepted: " + playerSessionId)
+	}
+
+	b.mAccepted[playerSessionId] = true
+	return nil
+}
+
+func (b *StandaloneBackend) RemovePlayerSession(playerSessionId string) error {
+	b.mLock.Lock()
+	defer b.mLock.Unlock()
+
+	if !b.mAccepted[playerSessionId] {
+		return errors.New("player session not accepted: " + playerSessionId)
+	}
+
+	// Accepted player sessions can't be used again, like on GameLift
+	return nil
+}
+
+func (b *StandaloneBackend) DescribePlayerSession(playerSessionId string) (*model.PlayerSession, error) {
+	b.mLock.Lock()
+	defer b.mLock.Unlock()
+
+	player, ok := b.mAllowlist[playerSessionId]
+	if b.mAllowlist != nil && !ok {
+		return nil, errors.New("no such player session: " + playerSessionId)
+	}
+
+	// made-up player ID, stable for the player session ID. The opponent sees it, so it mustn't give the ID away
+	if player.mPlayerId == "" {
+		sum := sha256.Sum256([]byte(playerSessionId))
+		player.mPlayerId = "player-" + hex.EncodeToString(sum[:4])
+	}
+
+	return &model.PlayerSession{
+		PlayerID:        player.mPlayerId,
+		PlayerSessionID: playerSessionId,
+		FleetID:         "standalone",
+		PlayerData:      player.mPlayerData,
+	}, nil
+}
+
+func (b *StandaloneBackend) StartMatchBackfill(req request.StartMatchBackfillRequest) (string, error) {
+	return "", errors.New("no FlexMatch backfill without GameLift")
+}
+
+func (b *StandaloneBackend) StopMatchBackfill(req request.StopMatchBackfillRequest) error {
+	return nil
+}
+
+func (b *StandaloneBackend) GetTerminationTime() (time.Time, error) {
+	return time.Time{}, errors.New("no termination time without GameLift")
+}
+
+func (b *StandaloneBackend) Destroy() error {
+	if b.mHttpServer != nil {
+		httpServer := b.mHttpServer
+		b.mHttpServer = nil
+		return httpServer.Close()
+	}
+	return nil
+}
+
+// Disconnected is nil, as there is no connection to lose
+func (b *StandaloneBackend) Disconnected() <-chan error {
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"aws/amazon-gamelift-go-sdk/server"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"closed", net.ErrClosed, true},
+		{"eof", io.EOF, true},
+		{"wrapped reset", fmt.Errorf("write: %w", syscall.ECONNRESET), true},
+		{"dial", &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{"service error", errors.New("player session psess-1 is COMPLETED"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsConnectionError(tt.err); got != tt.want {
+				t.Errorf("IsConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEpochTime(t *testing.T) {
+	want := time.Date(2026, 10, 19, 12, 0, 30, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		v    int64
+	}{
+		{"seconds", want.Unix()},
+		{"milliseconds", want.UnixMilli()},
+		{"microseconds", want.UnixMicro()},
+		{"ticks", want.UnixNano()/100 + DOTNET_EPOCH_TICKS},
+		{"nanoseconds", want.UnixNano()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EpochTime(tt.v); !got.Equal(want) {
+				t.Errorf("EpochTime(%d) = %s, want %s", tt.v, got.UTC(), want)
+			}
+		})
+	}
+}
+
+// reconnectBackend loses its connection on demand and counts how often it was made again,
+// and how often that happened under the game lock
+type reconnectBackend struct {
+	StandaloneBackend
+	mManager *GameLiftManager
+	mLost    chan error
+	mReadies int32
+	mLocked  int32
+}
+
+func (b *reconnectBackend) ProcessReady(params server.ProcessParameters) error {
+	if b.mManager.mLock.TryLock() {
+		b.mManager.mLock.Unlock()
+	} else {
+		atomic.AddInt32(&b.mLocked, 1)
+	}
+	atomic.AddInt32(&b.mReadies, 1)
+	return nil
+}
+
+func (b *reconnectBackend) Disconnected() <-chan error { return b.mLost }
+
+func TestReconnectOnLostConnection(t *testing.T) {
+	b := &reconnectBackend{mLost: make(chan error, 1)}
+	g := &GameLiftManager{mBackend: b}
+	b.mManager = g
+	go g.KeepSDKConnected()
+
+	for i := int32(1); i <= 2; i++ {
+		b.mLost <- io.EOF
+		for deadline := time.Now().Add(time.Second); atomic.LoadInt32(&b.mReadies) < i; time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("ProcessReady called %d times, want %d", atomic.LoadInt32(&b.mReadies), i)
+			}
+		}
+	}
+	if locked := atomic.LoadInt32(&b.mLocked); locked != 0 {
+		t.Errorf("ProcessReady called %d times under the game lock, want 0", locked)
+	}
+}
+
+func TestRecycleProcess(t *testing.T) {
+	b := &reconnectBackend{}
+	g := &GameLiftManager{mBackend: b, mReuseProcess: true, mActivated: true, mStateFilename: t.TempDir() + "/state"}
+	g.mServerParameters.ProcessID = "gomoku-1"
+	g.mGameSessionId = "gsess-1"
+	b.mManager = g
+
+	g.mLock.Lock()
+	g.mSessionCount++
+	g.EndGameSession()
+	g.mLock.Unlock()
+
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		g.mLock.Lock()
+		activated := g.mActivated
+		g.mLock.Unlock()
+		if activated {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("not registered again")
+		}
+	}
+
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+	if g.mServerParameters.ProcessID == "gomoku-1" || g.mGameSessionId != "" {
+		t.Errorf("process ID %q, game session %q after reuse", g.mServerParameters.ProcessID, g.mGameSessionId)
+	}
+	if readies, locked := atomic.LoadInt32(&b.mReadies), atomic.LoadInt32(&b.mLocked); readies != 1 || locked != 0 {
+		t.Errorf("ProcessReady called %d times, %d under the game lock, want 1, 0", readies, locked)
+	}
+}
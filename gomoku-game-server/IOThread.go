@@ -24,85 +24,207 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 )
 
 func DoIocpJob(conn net.Conn, ps *PlayerSession, wg *sync.WaitGroup) {
 	defer ps.mConn.Close()
 	defer wg.Done()
+	defer atomic.AddInt32(&ps.mGameLiftManager.mConnections, -1)
+	defer ps.OnDisconnect()
 
 	var buf [1024]byte
-	var playerId string
 
 	for {
 		n, err := ps.mConn.Read(buf[0:])
 
 		if checkError(err) {
 			myLogger.Print("Exiting go routine")
+			ps.Disconnect(DR_COMPLETION_ERROR)
 			break
 		}
 
-		if n < 4 {
-			myLogger.Print("Read Error too short length: ", n)
-			ps.Disconnect(DR_ACTIVE)
+		/// one packet at a time with everything else touching the game. See GameLiftManager.mLock
+		ps.mGameLiftManager.mLock.Lock()
+		ok := HandlePacket(ps, buf[0:], n)
+		ps.mGameLiftManager.mLock.Unlock()
+
+		if !ok {
 			return
 		}
+	}
+}
 
-		// assume Intel CPU (little Endian)
-		mSize := binary.LittleEndian.Uint16(buf[0:2])
-		mType := binary.LittleEndian.Uint16(buf[2:4])
+// HandlePacket dispatches the packet in buf[0:n]. Returns false after dropping the connection over a protocol violation.
+func HandlePacket(ps *PlayerSession, buf []byte, n int) bool {
+	var playerId string
 
-		fmt.Println("size: ", mSize)
-		fmt.Println("type: ", mType)
+	if n < 4 {
+		myLogger.Print("Read Error too short length: ", n)
+		ps.SendError(EC_PROTOCOL_VIOLATION, PKT_NONE, 0)
+		ps.Disconnect(DR_ACTIVE)
+		return false
+	}
 
-		if mType >= uint16(PKT_MAX) || mType <= uint16(PKT_NONE) {
+	// assume Intel CPU (little Endian)
+	mSize := binary.LittleEndian.Uint16(buf[0:2])
+	mType := binary.LittleEndian.Uint16(buf[2:4])
+
+	fmt.Println("size: ", mSize)
+	fmt.Println("type: ", mType)
+
+	if mType >= uint16(PKT_MAX) || mType <= uint16(PKT_NONE) {
+		ps.SendError(EC_PROTOCOL_VIOLATION, PacketTypes(mType), 0)
+		ps.Disconnect(DR_ACTIVE)
+		return false
+	}
+
+	switch PacketTypes(mType) {
+	case PKT_CS_START:
+		if mSize > 2+2+MAX_SESSION_LEN {
+			myLogger.Print("PKT_CS_START size too short length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_START, 0)
 			ps.Disconnect(DR_ACTIVE)
-			return
+			return false
+		}
+		playerId = string(bytes.Trim(buf[4:], "\u0000"))
+		Handler_PKT_CS_START(ps, playerId)
+
+	case PKT_CS_SPECTATE:
+		// Spectate message structure
+		// mSize (2byte)
+		// mType (2byte)
+		// mToken (up to MAX_SESSION_LEN byte) spectator token
+		if mSize < 4 || int(mSize) > n || mSize > 2+2+MAX_SESSION_LEN {
+			myLogger.Print("PKT_CS_SPECTATE size mismatch length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_SPECTATE, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
+		}
+		Handler_PKT_CS_SPECTATE(ps, string(bytes.Trim(buf[4:mSize], "\u0000")))
+
+	case PKT_CS_CAPABILITIES:
+		// Capabilities message structure
+		// mSize (2byte)
+		// mType (2byte)
+		// mCapabilities (4byte) ClientCapability flags
+		if mSize != 8 {
+			myLogger.Print("PKT_CS_CAPABILITIES size mismatch length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_CAPABILITIES, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
+		}
+		Handler_PKT_CS_CAPABILITIES(ps, ClientCapability(binary.LittleEndian.Uint32(buf[4:8])))
+
+	case PKT_CS_RESYNC:
+		Handler_PKT_CS_RESYNC(ps)
+
+	case PKT_CS_EXIT:
+		if mSize > 2+2+MAX_SESSION_LEN {
+			myLogger.Print("PKT_CS_EXIT size too short length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_EXIT, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
+		}
+		playerId = string(bytes.Trim(buf[4:], "\u0000"))
+		Handler_PKT_CS_EXIT(ps, playerId)
+
+	case PKT_CS_PUT_STONE:
+		// PutStone message structure
+		// mSize (2byte)
+		// mType (2byte)
+		// mXpos (4byte)
+		// mYpos (4byte)
+		// mCorrelationId (4byte, optional. Echoed back in PKT_SC_ERROR)
+		// mMoveSeq (4byte, optional with mBoardVersion. Client move sequence number starting from 1)
+		// mBoardVersion (4byte, optional with mMoveSeq. MoveNumber of the last PKT_SC_BOARD_STATUS seen)
+		if mSize != 12 && mSize != 16 && mSize != 24 {
+			myLogger.Print("PKT_CS_PUT_STONE size mismatch length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_PUT_STONE, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
+		}
+
+		xpos := binary.LittleEndian.Uint32(buf[4:8])
+		ypos := binary.LittleEndian.Uint32(buf[8:12])
+
+		var correlationId uint32
+		if mSize >= 16 {
+			correlationId = binary.LittleEndian.Uint32(buf[12:16])
+		}
+
+		if mSize == 24 {
+			moveSeq := binary.LittleEndian.Uint32(buf[16:20])
+			boardVersion := binary.LittleEndian.Uint32(buf[20:24])
+			Handler_PKT_CS_PUT_STONE_SEQ(ps, int(xpos), int(ypos), correlationId, moveSeq, boardVersion)
+		} else {
+			Handler_PKT_CS_PUT_STONE(ps, int(xpos), int(ypos), correlationId)
+		}
+
+	case PKT_CS_CHAT:
+		// Chat message structure
+		// mSize (2byte)
+		// mType (2byte)
+		// mMessage (up to MAX_CHAT_LEN byte) UTF-8
+		if mSize < 4 || int(mSize) > n {
+			myLogger.Print("PKT_CS_CHAT size mismatch length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_CHAT, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
+		}
+		Handler_PKT_CS_CHAT(ps, string(bytes.TrimRight(buf[4:mSize], "\u0000")))
+
+	case PKT_CS_EMOTE:
+		// Emote message structure
+		// mSize (2byte)
+		// mType (2byte)
+		// mEmote (2byte)
+		if mSize != 6 {
+			myLogger.Print("PKT_CS_EMOTE size mismatch length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_EMOTE, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
+		}
+		Handler_PKT_CS_EMOTE(ps, Emote(binary.LittleEndian.Uint16(buf[4:6])))
+
+	case PKT_CS_MUTE:
+		// Mute message structure
+		// mSize (2byte)
+		// mType (2byte)
+		// mMute (1byte) 1 to mute the opponent, 0 to unmute
+		if mSize != 5 {
+			myLogger.Print("PKT_CS_MUTE size mismatch length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_MUTE, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
 		}
+		Handler_PKT_CS_MUTE(ps, buf[4] != 0)
 
-		switch PacketTypes(mType) {
-		case PKT_CS_START:
-			if mSize > 2+2+MAX_SESSION_LEN {
-				myLogger.Print("PKT_CS_START size too short length: ", mSize)
-				ps.Disconnect(DR_ACTIVE)
-				return
-			}
-			playerId = string(bytes.Trim(buf[4:], "\u0000"))
-			Handler_PKT_CS_START(ps, playerId)
-
-		case PKT_CS_EXIT:
-			if mSize > 2+2+MAX_SESSION_LEN {
-				myLogger.Print("PKT_CS_EXIT size too short length: ", mSize)
-				ps.Disconnect(DR_ACTIVE)
-				return
-			}
-			playerId = string(bytes.Trim(buf[4:], "\u0000"))
-			Handler_PKT_CS_EXIT(ps, playerId)
-
-		case PKT_CS_PUT_STONE:
-			if mSize > 12 {
-				myLogger.Print("PKT_CS_PUT_STONE size too short length: ", mSize)
-				ps.Disconnect(DR_ACTIVE)
-				return
-			}
-
-			xpos := binary.LittleEndian.Uint32(buf[4:8])
-			ypos := binary.LittleEndian.Uint32(buf[8:12])
-
-			Handler_PKT_CS_PUT_STONE(ps, int(xpos), int(ypos))
-
-		case PKT_CS_PING:
-			if mSize > 2+2+MAX_SESSION_LEN {
-				myLogger.Print("PKT_CS_PING size too short length: ", mSize)
-				ps.Disconnect(DR_ACTIVE)
-				return
-			}
-			playerId = string(bytes.Trim(buf[4:], "\u0000"))
-			Handler_PKT_CS_PING(playerId)
-
-		default:
-			myLogger.Print("Error Unknown messge type: ", mType)
+	case PKT_CS_PAUSE_REQUEST:
+		Handler_PKT_CS_PAUSE(ps, PKT_CS_PAUSE_REQUEST)
+
+	case PKT_CS_PAUSE_ACCEPT:
+		Handler_PKT_CS_PAUSE(ps, PKT_CS_PAUSE_ACCEPT)
+
+	case PKT_CS_RESUME:
+		Handler_PKT_CS_PAUSE(ps, PKT_CS_RESUME)
+
+	case PKT_CS_PING:
+		if mSize > 2+2+MAX_SESSION_LEN {
+			myLogger.Print("PKT_CS_PING size too short length: ", mSize)
+			ps.SendError(EC_PROTOCOL_VIOLATION, PKT_CS_PING, 0)
+			ps.Disconnect(DR_ACTIVE)
+			return false
 		}
+		playerId = string(bytes.Trim(buf[4:], "\u0000"))
+		Handler_PKT_CS_PING(playerId)
+
+	default:
+		myLogger.Print("Error Unknown messge type: ", mType)
+		ps.SendError(EC_PROTOCOL_VIOLATION, PacketTypes(mType), 0)
 	}
+
+	return true
 }
 
 func Handler_PKT_CS_START(session *PlayerSession, playerId string) {
@@ -110,13 +232,156 @@ func Handler_PKT_CS_START(session *PlayerSession, playerId string) {
 	session.PlayerReady(playerId)
 }
 
+func Handler_PKT_CS_SPECTATE(session *PlayerSession, token string) {
+	myLogger.Print("PKT_CS_SPECTATE from ", session.mClientAddr.String())
+	session.SpectatorReady(token)
+}
+
 func Handler_PKT_CS_EXIT(session *PlayerSession, playerId string) {
 	myLogger.Print("PKT_CS_EXIT from ", playerId)
 	session.PlayerExit(playerId)
 }
 
-func Handler_PKT_CS_PUT_STONE(session *PlayerSession, xpos int, ypos int) {
-	session.mGameLiftManager.mGameSession.PutStone(session, xpos, ypos)
+func Handler_PKT_CS_PUT_STONE(session *PlayerSession, xpos int, ypos int, correlationId uint32) {
+	var ec ErrorCode
+
+	if session.mGameLiftManager.mGameSession == nil {
+		ec = EC_GAME_NOT_STARTED
+	} else {
+		ec = session.mGameLiftManager.mGameSession.PutStone(session, xpos, ypos)
+	}
+
+	if ec != EC_NONE {
+		if false == session.SendError(ec, PKT_CS_PUT_STONE, correlationId) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+func Handler_PKT_CS_PUT_STONE_SEQ(session *PlayerSession, xpos int, ypos int, correlationId uint32, moveSeq uint32, boardVersion uint32) {
+	var ec ErrorCode
+
+	if session.mGameLiftManager.mGameSession == nil {
+		ec = EC_GAME_NOT_STARTED
+	} else {
+		ec = session.mGameLiftManager.mGameSession.SubmitMove(session, xpos, ypos, moveSeq, boardVersion)
+	}
+
+	if ec != EC_NONE {
+		if false == session.SendError(ec, PKT_CS_PUT_STONE, correlationId) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+func Handler_PKT_CS_CAPABILITIES(session *PlayerSession, capabilities ClientCapability) {
+	myLogger.Printf("PKT_CS_CAPABILITIES from %s: 0x%x\n", session.mClientAddr.String(), capabilities)
+
+	if false == session.SetCapabilities(capabilities) {
+		session.Disconnect(DR_SENDBUFFER_ERROR)
+		return
+	}
+
+	// Late negotiation after the game started. Bring the client up to date before deltas arrive.
+	// Spectators only ever get full boards from their delayed feed.
+	gs := session.mGameLiftManager.mGameSession
+	if gs != nil && gs.mBoardStatus != nil && session.HasCapability(CAP_DELTA_BOARD) && !session.mSpectator {
+		gs.SendGameStatus(session)
+	}
+}
+
+func Handler_PKT_CS_RESYNC(session *PlayerSession) {
+	gs := session.mGameLiftManager.mGameSession
+
+	// The live board would defeat the spectator delay
+	if session.mSpectator {
+		if false == session.SendError(EC_NOT_SEATED, PKT_CS_RESYNC, 0) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+		return
+	}
+
+	if gs == nil || gs.mBoardStatus == nil {
+		if false == session.SendError(EC_GAME_NOT_STARTED, PKT_CS_RESYNC, 0) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+		return
+	}
+
+	gs.SendGameStatus(session)
+}
+
+func Handler_PKT_CS_CHAT(session *PlayerSession, message string) {
+	var ec ErrorCode
+
+	if session.mGameLiftManager.mGameSession == nil {
+		ec = EC_GAME_NOT_STARTED
+	} else {
+		ec = session.mGameLiftManager.mGameSession.RelayChat(session, message)
+	}
+
+	if ec != EC_NONE {
+		if false == session.SendError(ec, PKT_CS_CHAT, 0) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+func Handler_PKT_CS_EMOTE(session *PlayerSession, emote Emote) {
+	var ec ErrorCode
+
+	if session.mGameLiftManager.mGameSession == nil {
+		ec = EC_GAME_NOT_STARTED
+	} else {
+		ec = session.mGameLiftManager.mGameSession.RelayEmote(session, emote)
+	}
+
+	if ec != EC_NONE {
+		if false == session.SendError(ec, PKT_CS_EMOTE, 0) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+func Handler_PKT_CS_MUTE(session *PlayerSession, muted bool) {
+	var ec ErrorCode
+
+	if session.mGameLiftManager.mGameSession == nil {
+		ec = EC_GAME_NOT_STARTED
+	} else {
+		ec = session.mGameLiftManager.mGameSession.SetMute(session, muted)
+	}
+
+	if ec != EC_NONE {
+		if false == session.SendError(ec, PKT_CS_MUTE, 0) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+// Handler_PKT_CS_PAUSE handles PKT_CS_PAUSE_REQUEST, PKT_CS_PAUSE_ACCEPT and PKT_CS_RESUME
+func Handler_PKT_CS_PAUSE(session *PlayerSession, requestType PacketTypes) {
+	var ec ErrorCode
+
+	gs := session.mGameLiftManager.mGameSession
+	if gs == nil {
+		ec = EC_GAME_NOT_STARTED
+	} else {
+		switch requestType {
+		case PKT_CS_PAUSE_REQUEST:
+			ec = gs.RequestPause(session)
+		case PKT_CS_PAUSE_ACCEPT:
+			ec = gs.AcceptPause(session)
+		case PKT_CS_RESUME:
+			ec = gs.RequestResume(session)
+		}
+	}
+
+	if ec != EC_NONE {
+		if false == session.SendError(ec, requestType, 0) {
+			session.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
 }
 
 func Handler_PKT_CS_PING(playerId string) {
@@ -22,14 +22,31 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	CONNECTION_MARGIN = 4                // connections allowed over the seats and spectators, e.g. players reconnecting before their old connection is gone
+	LOGIN_TIMEOUT     = 10 * time.Second // a connection has this long to take a seat or start spectating
 )
 
 type IocpManager struct {
 	mListenPort int // use mListenPort instead of socket for defining struct
+	mListener   net.Listener
+	mAccepting  int32 // 1 while StartAccept takes connections. Read by the listener health probe
 }
 
 func (i *IocpManager) Initialize(listenPort int) bool {
+	var err error
+
 	i.mListenPort = listenPort
+	i.mListener, err = net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", i.mListenPort))
+	if err != nil {
+		myLogger.Print("Listen failed: ", err)
+		return false
+	}
+
 	return true
 }
 
@@ -39,22 +56,25 @@ func (i *IocpManager) StartIoThreads() bool {
 
 func (i *IocpManager) StartAccept(gl *GameLiftManager) {
 	var wg sync.WaitGroup
-	var num_player_session int
 
 	myLogger.Println("Listening client connection on port: ", i.mListenPort)
-	l, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", i.mListenPort))
-	if err != nil {
-		myLogger.Fatal(err)
-	}
 
+	atomic.StoreInt32(&i.mAccepting, 1)
+	defer atomic.StoreInt32(&i.mAccepting, 0)
+
+	maxConnections := gl.MaxConnections()
 	for {
-		if num_player_session == 2 {
-			myLogger.Print("All Players are joined. No longer accept additional player")
+		conn, err := i.mListener.Accept()
+		if err != nil {
+			myLogger.Print("Stop accepting client connection: ", err)
 			break
 		}
-		conn, err := l.Accept()
-		if err != nil {
-			myLogger.Fatal(err)
+
+		/// DoIocpJob counts it down when the connection is gone
+		if atomic.AddInt32(&gl.mConnections, 1) > maxConnections {
+			atomic.AddInt32(&gl.mConnections, -1)
+			myLogger.Print("Too many connections. Refusing ", conn.RemoteAddr().String())
+			conn.Close()
 			continue
 		}
 
@@ -72,7 +92,30 @@ func (i *IocpManager) StartAccept(gl *GameLiftManager) {
 
 		wg.Add(1)
 		playerSession.OnConnect(&wg)
-		num_player_session++
+
+		/// nobody holds a connection without a seat or a spectator place
+		time.AfterFunc(LOGIN_TIMEOUT, func() {
+			gl.mLock.Lock()
+			defer gl.mLock.Unlock()
+
+			if !playerSession.IsValid() && !playerSession.mSpectator {
+				myLogger.Print("No login in time. Disconnecting ", playerSession.mClientAddr.String())
+				playerSession.Disconnect(DR_UNAUTH)
+			}
+		})
 	}
 	wg.Wait()
 }
+
+// MaxConnections is the cap on client connections. Spectators connect at any time.
+// The seats are limited by GameLiftManager.AcceptPlayerSession
+func (g *GameLiftManager) MaxConnections() int32 {
+	return int32(MAX_PLAYER_PER_GAME + g.mMaxSpectators + CONNECTION_MARGIN)
+}
+
+// StopAccept closes the listener. StartAccept returns once the connections are gone
+func (i *IocpManager) StopAccept() {
+	if err := i.mListener.Close(); err != nil {
+		myLogger.Print("Closing listener failed: ", err)
+	}
+}
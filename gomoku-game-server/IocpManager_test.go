@@ -0,0 +1,71 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnectionCap(t *testing.T) {
+	g := &GameLiftManager{mMaxSpectators: 1}
+	saved := GGameLiftManager
+	GGameLiftManager = g
+	defer func() { GGameLiftManager = saved }()
+
+	i := &IocpManager{}
+	if !i.Initialize(0) {
+		t.Fatal("Initialize failed")
+	}
+	done := make(chan struct{})
+	go func() {
+		i.StartAccept(g)
+		close(done)
+	}()
+
+	var conns []net.Conn
+	defer func() {
+		for _, c := range conns {
+			c.Close()
+		}
+		i.StopAccept()
+		<-done
+	}()
+
+	for n := 0; n <= MAX_PLAYER_PER_GAME+g.mMaxSpectators+CONNECTION_MARGIN; n++ {
+		conn, err := net.Dial("tcp", i.mListener.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+
+	/// the last one over the cap is closed right away, the others wait for their login
+	for n, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, err := conn.Read(make([]byte, 1))
+
+		refused := err == io.EOF
+		if refused != (n == len(conns)-1) {
+			t.Errorf("connection %d: read error %v", n, err)
+		}
+	}
+}
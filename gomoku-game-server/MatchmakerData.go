@@ -0,0 +1,160 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"aws/amazon-gamelift-go-sdk/model"
+	"encoding/json"
+	"errors"
+)
+
+const MATCH_SCORE_ATTRIBUTE = "score" // player attribute holding the rating, see matchmaking_rule1.yml
+
+// MatchmakerData is the match FlexMatch put into the game session:
+//
+//	{"matchId" : "...", "matchmakingConfigurationArn" : "...",
+//	 "teams" : [{"name" : "blue", "players" : [{"playerId" : "...", "attributes" : {"score" : {"attributeType" : "DOUBLE", "valueAttribute" : 1000}}}]}],
+//	 "autoBackfillMode" : "AUTOMATIC", "autoBackfillTicketId" : "..."}
+type MatchmakerData struct {
+	MatchId                     string      `json:"matchId"`
+	MatchmakingConfigurationArn string      `json:"matchmakingConfigurationArn"`
+	Teams                       []MatchTeam `json:"teams"`
+	AutoBackfillMode            string      `json:"autoBackfillMode"`
+	AutoBackfillTicketId        string      `json:"autoBackfillTicketId"`
+}
+
+type MatchTeam struct {
+	Name    string        `json:"name"`
+	Players []MatchPlayer `json:"players"`
+}
+
+type MatchPlayer struct {
+	PlayerId   string                    `json:"playerId"`
+	Attributes map[string]MatchAttribute `json:"attributes"`
+}
+
+// MatchAttribute value is a string, number, string list or string-to-number map depending on attributeType
+type MatchAttribute struct {
+	AttributeType  string          `json:"attributeType"`
+	ValueAttribute json.RawMessage `json:"valueAttribute"`
+}
+
+// ParseMatchmakerData returns nil without error if the game session wasn't created by FlexMatch
+func ParseMatchmakerData(data string) (*MatchmakerData, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var match MatchmakerData
+	if err := json.Unmarshal([]byte(data), &match); err != nil {
+		return nil, err
+	}
+	if len(match.Teams) == 0 {
+		return nil, errors.New("no teams in MatchmakerData")
+	}
+
+	return &match, nil
+}
+
+// FindPlayer returns the player and team name, or nil if playerId isn't part of the match
+func (m *MatchmakerData) FindPlayer(playerId string) (*MatchPlayer, string) {
+	for i := range m.Teams {
+		for j := range m.Teams[i].Players {
+			if m.Teams[i].Players[j].PlayerId == playerId {
+				return &m.Teams[i].Players[j], m.Teams[i].Name
+			}
+		}
+	}
+	return nil, ""
+}
+
+// NumberAttribute returns the value of a number (DOUBLE) attribute
+func (p *MatchPlayer) NumberAttribute(name string) (float64, bool) {
+	attr, ok := p.Attributes[name]
+	if !ok {
+		return 0, false
+	}
+
+	var value float64
+	if err := json.Unmarshal(attr.ValueAttribute, &value); err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// RemovePlayer takes a player who left out of the match, so backfill looks for a replacement
+func (m *MatchmakerData) RemovePlayer(playerId string) bool {
+	for i := range m.Teams {
+		players := m.Teams[i].Players
+		for j := range players {
+			if players[j].PlayerId == playerId {
+				m.Teams[i].Players = append(players[:j:j], players[j+1:]...)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BackfillPlayers lists the players still in the match the way StartMatchBackfill wants them
+func (m *MatchmakerData) BackfillPlayers() []model.Player {
+	var players []model.Player
+
+	for _, team := range m.Teams {
+		for _, p := range team.Players {
+			attributes := make(map[string]model.AttributeValue)
+			for name, attr := range p.Attributes {
+				value, err := attr.AttributeValue()
+				if err != nil {
+					myLogger.Printf("[BACKFILL] Skipping attribute %s of %s: %s\n", name, p.PlayerId, err)
+					continue
+				}
+				attributes[name] = value
+			}
+
+			players = append(players, model.Player{
+				PlayerID:         p.PlayerId,
+				PlayerAttributes: attributes,
+				Team:             team.Name,
+			})
+		}
+	}
+
+	return players
+}
+
+func (a *MatchAttribute) AttributeValue() (model.AttributeValue, error) {
+	value := model.AttributeValue{AttrType: model.AttrType(a.AttributeType)}
+
+	var err error
+	switch value.AttrType {
+	case model.String:
+		err = json.Unmarshal(a.ValueAttribute, &value.S)
+	case model.Double:
+		err = json.Unmarshal(a.ValueAttribute, &value.N)
+	case model.StringList:
+		err = json.Unmarshal(a.ValueAttribute, &value.SL)
+	case model.StringDoubleMap:
+		err = json.Unmarshal(a.ValueAttribute, &value.SDM)
+	default:
+		err = errors.New("unknown attribute type " + a.AttributeType)
+	}
+
+	return value, err
+}
@@ -0,0 +1,179 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"aws/amazon-gamelift-go-sdk/model"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const testMatchmakerData = `{"matchId" : "m-1", "matchmakingConfigurationArn" : "arn:config",
+	"teams" : [
+		{"name" : "blue", "players" : [{"playerId" : "alice", "attributes" : {"score" : {"attributeType" : "DOUBLE", "valueAttribute" : 1200}}}]},
+		{"name" : "red", "players" : [{"playerId" : "bob", "attributes" : {"score" : {"attributeType" : "STRING", "valueAttribute" : "high"}}},
+			{"playerId" : "carol", "attributes" : {}}]}],
+	"autoBackfillMode" : "AUTOMATIC"}`
+
+func TestParseMatchmakerData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		wantErr  bool
+		wantNil  bool
+		teams    int
+		backfill string
+	}{
+		{name: "not FlexMatch", data: "", wantNil: true},
+		{name: "match", data: testMatchmakerData, teams: 2, backfill: "AUTOMATIC"},
+		{name: "invalid JSON", data: `{"teams" : [`, wantErr: true},
+		{name: "wrong type", data: `{"teams" : "blue"}`, wantErr: true},
+		{name: "no teams", data: `{"matchId" : "m-1"}`, wantErr: true},
+		{name: "empty teams", data: `{"matchId" : "m-1", "teams" : []}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match, err := ParseMatchmakerData(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseMatchmakerData error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr || tt.wantNil {
+				if match != nil {
+					t.Errorf("got %+v, want nil", match)
+				}
+				return
+			}
+			if len(match.Teams) != tt.teams || match.AutoBackfillMode != tt.backfill {
+				t.Errorf("%d teams, backfill mode %q, want %d, %q", len(match.Teams), match.AutoBackfillMode, tt.teams, tt.backfill)
+			}
+		})
+	}
+}
+
+func TestMatchPlayers(t *testing.T) {
+	match, err := ParseMatchmakerData(testMatchmakerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		playerId string
+		team     string // empty if not in the match
+		score    float64
+		hasScore bool
+	}{
+		{"alice", "blue", 1200, true},
+		{"bob", "red", 0, false},
+		{"carol", "red", 0, false},
+		{"mallory", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.playerId, func(t *testing.T) {
+			player, team := match.FindPlayer(tt.playerId)
+			if team != tt.team || (player == nil) != (tt.team == "") {
+				t.Fatalf("FindPlayer team %q, player %v, want team %q", team, player, tt.team)
+			}
+			if player == nil {
+				return
+			}
+			if score, ok := player.NumberAttribute(MATCH_SCORE_ATTRIBUTE); score != tt.score || ok != tt.hasScore {
+				t.Errorf("NumberAttribute = %v, %v, want %v, %v", score, ok, tt.score, tt.hasScore)
+			}
+		})
+	}
+}
+
+func TestRemovePlayer(t *testing.T) {
+	match, err := ParseMatchmakerData(testMatchmakerData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !match.RemovePlayer("bob") {
+		t.Fatal("bob not removed")
+	}
+	if match.RemovePlayer("bob") {
+		t.Error("bob removed twice")
+	}
+	if player, _ := match.FindPlayer("bob"); player != nil {
+		t.Error("bob still in the match")
+	}
+
+	/// carol has no attributes, but stays in the match with her team
+	players := match.BackfillPlayers()
+	if len(players) != 2 || players[0].PlayerID != "alice" || players[1].PlayerID != "carol" || players[1].Team != "red" {
+		t.Fatalf("BackfillPlayers = %+v", players)
+	}
+	if score := players[0].PlayerAttributes[MATCH_SCORE_ATTRIBUTE]; score.AttrType != model.Double || score.N != 1200 {
+		t.Errorf("alice's score %+v", score)
+	}
+}
+
+func TestAttributeValue(t *testing.T) {
+	tests := []struct {
+		name    string
+		attr    string
+		want    model.AttributeValue
+		wantErr bool
+	}{
+		{
+			name: "string",
+			attr: `{"attributeType" : "STRING", "valueAttribute" : "blue"}`,
+			want: model.AttributeValue{AttrType: model.String, S: "blue"},
+		},
+		{
+			name: "double",
+			attr: `{"attributeType" : "DOUBLE", "valueAttribute" : 1500.5}`,
+			want: model.AttributeValue{AttrType: model.Double, N: 1500.5},
+		},
+		{
+			name: "string list",
+			attr: `{"attributeType" : "STRING_LIST", "valueAttribute" : ["a", "b"]}`,
+			want: model.AttributeValue{AttrType: model.StringList, SL: []string{"a", "b"}},
+		},
+		{
+			name: "string double map",
+			attr: `{"attributeType" : "STRING_DOUBLE_MAP", "valueAttribute" : {"us-west-2" : 50}}`,
+			want: model.AttributeValue{AttrType: model.StringDoubleMap, SDM: map[string]float64{"us-west-2": 50}},
+		},
+		{name: "type mismatch", attr: `{"attributeType" : "DOUBLE", "valueAttribute" : "high"}`, wantErr: true},
+		{name: "unknown type", attr: `{"attributeType" : "BOOL", "valueAttribute" : true}`, wantErr: true},
+		{name: "no value", attr: `{"attributeType" : "STRING"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attr MatchAttribute
+			if err := json.Unmarshal([]byte(tt.attr), &attr); err != nil {
+				t.Fatal(err)
+			}
+
+			value, err := attr.AttributeValue()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AttributeValue error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(value, tt.want) {
+				t.Errorf("AttributeValue = %+v, want %+v", value, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+// BookEntry is an opening position and the replies to it.
+// Coordinates are relative to the first black stone and match in any of the 8 board symmetries.
+type BookEntry struct {
+	mStones  [][3]int // dx, dy, StoneType
+	mReplies [][2]int
+}
+
+var gOpeningBook = []BookEntry{
+	// White's second move: direct or indirect opening
+	{[][3]int{{0, 0, int(STONE_BLACK)}}, [][2]int{{0, 1}, {1, 1}}},
+
+	// Black's third move after direct opening (Kagetsu, Ugetsu)
+	{[][3]int{{0, 0, int(STONE_BLACK)}, {0, 1, int(STONE_WHITE)}}, [][2]int{{1, 1}, {1, 2}}},
+	// Black's third move after indirect opening
+	{[][3]int{{0, 0, int(STONE_BLACK)}, {1, 1, int(STONE_WHITE)}}, [][2]int{{2, 0}, {1, -1}}},
+
+	// White's fourth move
+	{[][3]int{{0, 0, int(STONE_BLACK)}, {0, 1, int(STONE_WHITE)}, {1, 1, int(STONE_BLACK)}}, [][2]int{{-1, -1}, {2, 2}, {1, 0}}},
+	{[][3]int{{0, 0, int(STONE_BLACK)}, {0, 1, int(STONE_WHITE)}, {1, 2, int(STONE_BLACK)}}, [][2]int{{1, 1}, {-1, 0}}},
+	{[][3]int{{0, 0, int(STONE_BLACK)}, {1, 1, int(STONE_WHITE)}, {2, 0, int(STONE_BLACK)}}, [][2]int{{1, 0}, {1, -1}}},
+	{[][3]int{{0, 0, int(STONE_BLACK)}, {1, 1, int(STONE_WHITE)}, {1, -1, int(STONE_BLACK)}}, [][2]int{{1, 0}, {0, -1}}},
+}
+
+var gBoardSymmetries = [8]func(int, int) (int, int){
+	func(x, y int) (int, int) { return x, y },
+	func(x, y int) (int, int) { return -x, y },
+	func(x, y int) (int, int) { return x, -y },
+	func(x, y int) (int, int) { return -x, -y },
+	func(x, y int) (int, int) { return y, x },
+	func(x, y int) (int, int) { return -y, x },
+	func(x, y int) (int, int) { return y, -x },
+	func(x, y int) (int, int) { return -y, -x },
+}
+
+// bookMove looks the loaded position up in gOpeningBook and picks one of the replies at random
+func (e *Engine) bookMove() (int, int, bool) {
+	var stones [][3]int
+	for x := 0; x < e.mSize; x++ {
+		for y := 0; y < e.mSize; y++ {
+			if st := e.mCells[x*e.mSize+y]; st != STONE_NONE {
+				stones = append(stones, [3]int{x, y, int(st)})
+			}
+		}
+	}
+
+	var replies [][2]int
+	for _, entry := range gOpeningBook {
+		if len(entry.mStones) != len(stones) {
+			continue
+		}
+
+		for _, origin := range stones {
+			if StoneType(origin[2]) != STONE_BLACK {
+				continue
+			}
+
+			for _, sym := range gBoardSymmetries {
+				matched := true
+				for _, s := range entry.mStones {
+					dx, dy := sym(s[0], s[1])
+					if e.at(origin[0]+dx, origin[1]+dy) != StoneType(s[2]) {
+						matched = false
+						break
+					}
+				}
+				if !matched {
+					continue
+				}
+
+				for _, r := range entry.mReplies {
+					dx, dy := sym(r[0], r[1])
+					if e.at(origin[0]+dx, origin[1]+dy) == STONE_NONE {
+						replies = append(replies, [2]int{origin[0] + dx, origin[1] + dy})
+					}
+				}
+			}
+		}
+	}
+
+	if len(replies) == 0 {
+		return 0, 0, false
+	}
+
+	r := replies[e.mRand.Intn(len(replies))]
+	return r[0], r[1], true
+}
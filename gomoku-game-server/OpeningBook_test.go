@@ -0,0 +1,101 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import "testing"
+
+func TestBookMove(t *testing.T) {
+	tests := []struct {
+		name   string
+		board  [][]byte
+		inBook bool
+		want   [][2]int // any of these
+	}{
+		{
+			name:   "white's second move",
+			board:  testBoard(".........", ".........", ".........", ".........", "....X....", ".........", ".........", ".........", "........."),
+			inBook: true,
+			want:   [][2]int{{3, 3}, {3, 4}, {3, 5}, {4, 3}, {4, 5}, {5, 3}, {5, 4}, {5, 5}},
+		},
+		{
+			name:   "direct opening",
+			board:  testBoard(".........", ".........", ".........", "....O....", "....X....", ".........", ".........", ".........", "........."),
+			inBook: true,
+			want:   [][2]int{{3, 3}, {5, 3}, {3, 2}, {5, 2}},
+		},
+		{
+			name:   "direct opening mirrored",
+			board:  testBoard(".........", ".........", ".........", ".........", "....X....", "....O....", ".........", ".........", "........."),
+			inBook: true,
+			want:   [][2]int{{3, 5}, {5, 5}, {3, 6}, {5, 6}},
+		},
+		{
+			name:   "indirect opening",
+			board:  testBoard(".........", ".........", ".........", ".........", "....X....", ".....O...", ".........", ".........", "........."),
+			inBook: true,
+			want:   [][2]int{{6, 4}, {4, 6}, {5, 3}, {3, 5}},
+		},
+		{
+			name:   "not in the book",
+			board:  testBoard(".........", ".........", ".........", ".........", "....X....", ".........", "....O....", ".........", "........."),
+			inBook: false,
+		},
+		{
+			name:   "empty board",
+			board:  testBoard(".........", ".........", ".........", ".........", ".........", ".........", ".........", ".........", "........."),
+			inBook: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			/// every seed may pick another reply
+			for seed := int64(1); seed <= 20; seed++ {
+				e := NewEngine(testEngineConfig(), seed)
+				e.load(tt.board)
+				x, y, ok := e.bookMove()
+
+				if ok != tt.inBook {
+					t.Fatalf("bookMove found %v, want %v", ok, tt.inBook)
+				}
+				if !ok {
+					return
+				}
+
+				found := false
+				for _, w := range tt.want {
+					found = found || (x == w[0] && y == w[1])
+				}
+				if !found {
+					t.Fatalf("seed %d: bookMove (%d, %d), want one of %v", seed, x, y, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchUsesBook(t *testing.T) {
+	config := testEngineConfig()
+	config.mUseBook = true
+	board := testBoard(".........", ".........", ".........", ".........", "....X....", ".........", ".........", ".........", ".........")
+
+	if result := NewEngine(config, 1).Search(board, STONE_WHITE); !result.mFromBook {
+		t.Errorf("Search played (%d, %d) without the book", result.mXpos, result.mYpos)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// RequestPause asks the opponent of psess to pause the game. The pause time is charged to psess.
+// Bots accept right away.
+func (gs *GameSession) RequestPause(psess GamePlayer) ErrorCode {
+	st := gs.SeatOf(psess)
+	if st == STONE_NONE {
+		return EC_NOT_SEATED
+	}
+
+	if gs.mGameStatus != GS_STARTED {
+		return gs.NotPlayingError()
+	}
+
+	remaining := gs.PauseRemaining(st)
+	if remaining <= 0 {
+		myLogger.Print("[PAUSE] No pause time left: ", psess.GetPlayerSessionId())
+		return EC_PAUSE_LIMIT
+	}
+
+	gs.mPauseRequestedBy = st
+	myLogger.Printf("[PAUSE] %s requests a pause (%s left)\n", psess.GetPlayerSessionId(), remaining)
+
+	opponent := gs.Opponent(psess)
+	if IsBot(opponent) {
+		return gs.AcceptPause(opponent)
+	}
+
+	outPacket := gs.MakePauseRequestPacket(st, remaining)
+	if false == opponent.PostSend(outPacket, len(outPacket)) {
+		opponent.Disconnect(DR_SENDBUFFER_ERROR)
+	}
+
+	return EC_NONE
+}
+
+// AcceptPause starts the pause requested by the opponent of psess
+func (gs *GameSession) AcceptPause(psess GamePlayer) ErrorCode {
+	st := gs.SeatOf(psess)
+	if st == STONE_NONE {
+		return EC_NOT_SEATED
+	}
+
+	if gs.mGameStatus != GS_STARTED {
+		return gs.NotPlayingError()
+	}
+
+	if gs.mPauseRequestedBy == STONE_NONE || gs.mPauseRequestedBy == st {
+		return EC_NO_PAUSE_REQUEST
+	}
+
+	now := time.Now()
+	gs.mPausedBy = gs.mPauseRequestedBy
+	gs.mPauseRequestedBy = STONE_NONE
+	gs.mPauseStart = now
+	gs.mGameStatus = GS_PAUSED
+	gs.mClock.Pause(now)
+	gs.StopTurnTimer()
+
+	// Resume by itself once the pause time of the requester runs out
+	pauseId := gs.mPauseCount + 1
+	gs.mPauseCount = pauseId
+	gs.mPauseTimer = time.AfterFunc(gs.PauseRemaining(gs.mPausedBy), func() {
+		gs.mGameLiftManager.mLock.Lock()
+		defer gs.mGameLiftManager.mLock.Unlock()
+
+		gs.PauseTimedOut(pauseId, time.Now())
+	})
+
+	myLogger.Printf("[PAUSE] Game paused, charged to %s (%s left)\n", gs.PlayerOf(gs.mPausedBy).GetPlayerSessionId(), gs.PauseRemaining(gs.mPausedBy))
+
+	gs.BroadcastGameStatus()
+	return EC_NONE
+}
+
+// RequestResume ends the pause. Either player may resume early.
+func (gs *GameSession) RequestResume(psess GamePlayer) ErrorCode {
+	if gs.SeatOf(psess) == STONE_NONE {
+		return EC_NOT_SEATED
+	}
+
+	if gs.mGameStatus != GS_PAUSED {
+		return EC_NOT_PAUSED
+	}
+
+	myLogger.Print("[PAUSE] Resume requested by ", psess.GetPlayerSessionId())
+	gs.ResumeGame(time.Now())
+	return EC_NONE
+}
+
+// PauseTimedOut resumes the game once the pause time of the requester ran out, unless that pause is over already
+func (gs *GameSession) PauseTimedOut(pauseId int, now time.Time) {
+	if gs.mGameStatus == GS_PAUSED && gs.mPauseCount == pauseId {
+		myLogger.Print("[PAUSE] Pause time is up")
+		gs.ResumeGame(now)
+	}
+}
+
+func (gs *GameSession) ResumeGame(now time.Time) {
+	if gs.mGameStatus != GS_PAUSED {
+		return
+	}
+
+	if gs.mPauseTimer != nil {
+		gs.mPauseTimer.Stop()
+		gs.mPauseTimer = nil
+	}
+
+	gs.mPauseUsed[gs.mPausedBy] += now.Sub(gs.mPauseStart)
+	gs.mPausedBy = STONE_NONE
+	gs.mGameStatus = GS_STARTED
+	gs.mClock.Resume(now)
+	gs.ArmTurnTimer()
+
+	myLogger.Printf("[PAUSE] Game resumed. Pause used black %s, white %s\n", gs.mPauseUsed[STONE_BLACK], gs.mPauseUsed[STONE_WHITE])
+
+	gs.BroadcastGameStatus()
+}
+
+// PauseRemaining is how much more pause time st may use, including the running pause
+func (gs *GameSession) PauseRemaining(st StoneType) time.Duration {
+	used := gs.mPauseUsed[st]
+	if gs.mGameStatus == GS_PAUSED && gs.mPausedBy == st {
+		used += time.Since(gs.mPauseStart)
+	}
+
+	remaining := gs.mGameLiftManager.mMaxPause - used
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+func (gs *GameSession) PlayerOf(st StoneType) GamePlayer {
+	if st == STONE_BLACK {
+		return gs.mPlayerBlack
+	}
+	return gs.mPlayerWhite
+}
+
+// NotPlayingError is the error for a request which needs a running game
+func (gs *GameSession) NotPlayingError() ErrorCode {
+	switch gs.mGameStatus {
+	case GS_NOT_STARTED:
+		return EC_GAME_NOT_STARTED
+	case GS_PAUSED:
+		return EC_GAME_PAUSED
+	default:
+		return EC_GAME_OVER
+	}
+}
+
+func (gs *GameSession) MakePauseRequestPacket(requester StoneType, remaining time.Duration) []byte {
+	var size, ptype uint16
+
+	// PauseRequest message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// StoneType (1byte) requester
+	// mRemaining (4byte) pause time the requester has left, in milliseconds
+	var outPacket [2 + 2 + 1 + 4]byte
+
+	size = 2 + 2 + 1 + 4
+	ptype = uint16(PKT_SC_PAUSE_REQUEST)
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	outPacket[4] = byte(requester)
+	binary.LittleEndian.PutUint32(outPacket[5:], uint32(remaining/time.Millisecond))
+
+	return outPacket[0:size]
+}
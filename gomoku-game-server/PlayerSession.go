@@ -19,9 +19,11 @@
 package main
 
 import (
+	"encoding/binary"
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 )
 
 type DisconnectReason int
@@ -36,6 +38,7 @@ const (
 	DR_SENDBUFFER_ERROR DisconnectReason = 6
 	DR_UNAUTH           DisconnectReason = 7
 	DR_LOGOUT           DisconnectReason = 8
+	DR_SERVER_SHUTDOWN  DisconnectReason = 9
 )
 
 func (ps *PlayerSession) OnConnect(wg *sync.WaitGroup) bool {
@@ -44,7 +47,7 @@ func (ps *PlayerSession) OnConnect(wg *sync.WaitGroup) bool {
 	// Let's Start goroutine here with PlayerSession pointer as argument
 
 	myLogger.Print("Session OnConnect() implement this")
-	ps.mConnected++
+	atomic.StoreInt32(&ps.mConnected, 1)
 
 	// Run go routine for communication with each player
 	go DoIocpJob(ps.mConn, ps, wg)
@@ -53,11 +56,10 @@ func (ps *PlayerSession) OnConnect(wg *sync.WaitGroup) bool {
 }
 
 func (ps *PlayerSession) Disconnect(dr DisconnectReason) {
-	/*
-		/// already disconnected or disconnecting...
-		if (0 == InterlockedExchange(&mConnected, 0))
-			return;
-	*/
+	/// already disconnected or disconnecting...
+	if 0 == atomic.SwapInt32(&ps.mConnected, 0) {
+		return
+	}
 
 	myLogger.Printf("[DEBUG] Client Disconnected: Reason=%d %s\n", dr, ps.mClientAddr.String())
 
@@ -66,7 +68,8 @@ func (ps *PlayerSession) Disconnect(dr DisconnectReason) {
 		myLogger.Printf("Error when setting linger: %s", err)
 	}
 
-	ps.OnDisconnect(dr)
+	/// OnDisconnect follows on the goroutine of the connection. The game may be calling us while holding mLock
+	atomic.StoreInt32(&ps.mDisconnectReason, int32(dr))
 	ps.mConn.Close()
 }
 
@@ -93,6 +96,62 @@ func (ps *PlayerSession) PostSend(data []byte, len int) bool {
 	return true
 }
 
+func (ps *PlayerSession) SendError(ec ErrorCode, requestType PacketTypes, correlationId uint32) bool {
+	var size uint16
+
+	// Error message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// mErrorCode (2byte)
+	// mRequestType (2byte) packet type of the rejected request
+	// mCorrelationId (4byte) echoed from the request, 0 if none
+	// mMoveNumber (4byte) current board version, 0 if no game session
+	var outPacket [2 + 2 + 2 + 2 + 4 + 4]byte
+	var moveNumber uint32
+
+	size = 2 + 2 + 2 + 2 + 4 + 4
+
+	// The live move number would tell a spectator more than the delayed feed does
+	if gs := ps.mGameLiftManager.mGameSession; gs != nil && !ps.mSpectator {
+		moveNumber = gs.mMoveNumber
+	}
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], uint16(PKT_SC_ERROR))
+	binary.LittleEndian.PutUint16(outPacket[4:], uint16(ec))
+	binary.LittleEndian.PutUint16(outPacket[6:], uint16(requestType))
+	binary.LittleEndian.PutUint32(outPacket[8:], correlationId)
+	binary.LittleEndian.PutUint32(outPacket[12:], moveNumber)
+
+	myLogger.Printf("[ERROR] Rejected request type %d (correlation %d) from %s: error code %d\n", requestType, correlationId, ps.mClientAddr.String(), ec)
+
+	return ps.PostSend(outPacket[0:size], int(size))
+}
+
+func (ps *PlayerSession) SetCapabilities(requested ClientCapability) bool {
+	var size uint16
+
+	ps.mCapabilities = requested & CAP_SUPPORTED
+
+	// Capabilities message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// mCapabilities (4byte) subset of requested flags which the server enabled
+	var outPacket [2 + 2 + 4]byte
+
+	size = 2 + 2 + 4
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], uint16(PKT_SC_CAPABILITIES))
+	binary.LittleEndian.PutUint32(outPacket[4:], uint32(ps.mCapabilities))
+
+	return ps.PostSend(outPacket[0:size], int(size))
+}
+
+func (ps *PlayerSession) HasCapability(c ClientCapability) bool {
+	return ps.mCapabilities&c != 0
+}
+
 func (ps *PlayerSession) FlushSend() bool {
 	return true
 }
@@ -106,11 +165,16 @@ type PlayerSession struct {
 	//mRecvBuffer CircularBuffer
 	//mSendBuffer CircularBuffer
 
-	mConnected int
+	mConnected        int32
+	mDisconnectReason int32 // DisconnectReason, for OnDisconnect
 
 	mPlayerSessionId string
 	mPlayerName      string
-	mScore           int
+	mScore           int              // rating from the matchmaker data
+	mTeam            string           // FlexMatch team. Empty if not matchmade
+	mLastMoveSeq     uint32           // last accepted move sequence number from PKT_CS_PUT_STONE
+	mCapabilities    ClientCapability // negotiated with PKT_CS_CAPABILITIES
+	mSpectator       bool             // read-only connection fed by GameSession.RunSpectatorFeed
 	mGameLiftManager *GameLiftManager
 }
 
@@ -123,20 +187,30 @@ func (ps *PlayerSession) IsValid() bool {
 }
 
 func (ps *PlayerSession) PlayerReady(playerSessionId string) {
+	/// the same player coming back on a new connection
+	if ps.mGameLiftManager.ReconnectPlayerSession(ps, playerSessionId) {
+		myLogger.Print("[PLAYER] PlayerReconnected: ", playerSessionId)
+		return
+	}
+
+	/// nobody new once the server is going away
+	if ps.mGameLiftManager.IsShuttingDown() {
+		ps.SendError(EC_SHUTTING_DOWN, PKT_CS_START, 0)
+		ps.Disconnect(DR_UNAUTH)
+		return
+	}
+
 	if ps.mGameLiftManager.AcceptPlayerSession(ps, playerSessionId) {
 		ps.mPlayerSessionId = playerSessionId
 
-		myLogger.Print("Implement PlayerSession  PlalyerReady(). Let's Call this from GameLiftManager module.")
+		/// AcceptPlayerSession set the name from DescribePlayerSessions
+		myLogger.Print(ps.mPlayerName)
 
-		playerSession, err := GGameLiftManager.DescribePlayerSessions(playerSessionId)
-		if err != nil {
-			myLogger.Fatal(err.Error())
+		if ps.mSpectator {
+			myLogger.Print("[SPECTATOR] SpectatorReady: ", playerSessionId)
+			return
 		}
 
-		myLogger.Print(playerSession.PlayerID)
-
-		ps.mPlayerName = playerSession.PlayerID
-
 		myLogger.Print("[PLAYER] PlayerReady: ", playerSessionId)
 		ps.mGameLiftManager.CheckReadyAll()
 
@@ -144,11 +218,19 @@ func (ps *PlayerSession) PlayerReady(playerSessionId string) {
 	}
 
 	/// disconnect unauthed player
+	ps.SendError(EC_UNAUTHENTICATED, PKT_CS_START, 0)
 	ps.Disconnect(DR_UNAUTH)
 }
 
+func (ps *PlayerSession) SpectatorReady(token string) {
+	if ec := ps.mGameLiftManager.AcceptSpectatorToken(ps, token); ec != EC_NONE {
+		ps.SendError(ec, PKT_CS_SPECTATE, 0)
+		ps.Disconnect(DR_UNAUTH)
+	}
+}
+
 func (ps *PlayerSession) PlayerExit(playerSessionId string) {
-	ps.mGameLiftManager.RemovePlayerSession(ps, playerSessionId)
+	ps.mGameLiftManager.RemovePlayerSession(ps, playerSessionId, WR_RESIGNATION)
 
 	ps.mPlayerSessionId = ""
 
@@ -157,9 +239,26 @@ func (ps *PlayerSession) PlayerExit(playerSessionId string) {
 	ps.Disconnect(DR_LOGOUT)
 }
 
-func (ps *PlayerSession) OnDisconnect(dr DisconnectReason) {
+// OnDisconnect runs on the goroutine of the connection once it stops reading, after Disconnect
+func (ps *PlayerSession) OnDisconnect() {
+	dr := DisconnectReason(atomic.LoadInt32(&ps.mDisconnectReason))
+
+	GGameLiftManager.mLock.Lock()
+	defer GGameLiftManager.mLock.Unlock()
+
+	if ps.mSpectator {
+		GGameLiftManager.RemoveSpectator(ps)
+		ps.mPlayerSessionId = ""
+		return
+	}
+
 	if ps.IsValid() {
-		GGameLiftManager.RemovePlayerSession(ps, ps.mPlayerSessionId)
+		/// keep the seat for a while. The player session id is needed to find it again
+		if GGameLiftManager.PlayerDisconnected(ps, dr) {
+			return
+		}
+
+		GGameLiftManager.RemovePlayerSession(ps, ps.mPlayerSessionId, WR_ABANDONMENT)
 		ps.mPlayerSessionId = ""
 	}
 }
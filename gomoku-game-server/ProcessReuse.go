@@ -0,0 +1,144 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"github.com/google/uuid"
+)
+
+// EndGameSession is called once a game session is over and its players are gone.
+// The process exits, or with mReuseProcess gets ready for the next game session unless draining.
+func (g *GameLiftManager) EndGameSession() {
+	/// Shutdown ends the process by itself
+	if g.IsShuttingDown() {
+		return
+	}
+
+	if !g.mReuseProcess || g.IsDraining() || (g.mMaxSessions > 0 && g.mSessionCount >= g.mMaxSessions) {
+		g.TerminateGameSession(0)
+		return
+	}
+
+	g.RecycleProcess()
+}
+
+// RecycleProcess resets the state of the finished game session and registers the process again.
+// GameLift ends the server process along with its game session on ProcessEnding, so the next one
+// is registered like a new process, under a new process ID. That runs on its own goroutine,
+// as the SDK calls mustn't hold up the game lock.
+func (g *GameLiftManager) RecycleProcess() {
+	myLogger.Printf("[GAMELIFT] Game session %d of %d done. Reusing process\n", g.mSessionCount, g.mMaxSessions)
+
+	g.StopBackfill()
+	g.StopBotTimer()
+
+	// Let the post-game analysis finish and the results go out first
+	g.mPendingResults.Wait()
+
+	if gs := g.mGameSession; gs != nil {
+		gs.StopTimers()
+		gs.DisconnectSpectators()
+	}
+
+	g.mGameSession = nil
+	g.mPlayerReadyCount = 0
+	g.mCheckTerminationCount = 0
+	g.mHumanSessionCount = 0
+	g.mGameSessionId = ""
+	g.mMatchData = nil
+
+	/// unhealthy until registered again
+	g.mActivated = false
+
+	go g.RegisterAgain()
+}
+
+// RegisterAgain ends the registration of the finished game session and makes a new one
+func (g *GameLiftManager) RegisterAgain() {
+	g.mSDKLock.Lock()
+	defer g.mSDKLock.Unlock()
+
+	/// Shutdown ends the process by itself
+	if g.IsShuttingDown() {
+		return
+	}
+
+	if err := g.mBackend.ProcessEnding(); err != nil {
+		myLogger.Print("[GAMELIFT] ProcessEnding Fail: ", err.Error())
+	}
+	if err := g.mBackend.Destroy(); err != nil {
+		myLogger.Print("[GAMELIFT] Destroy Fail: ", err.Error())
+	}
+
+	g.mLock.Lock()
+	g.mServerParameters.ProcessID = uuid.NewString()
+	serverParameters := g.mServerParameters
+	processParameters := g.mProcessParameters
+	g.mLock.Unlock()
+
+	myLogger.Print("[GAMELIFT] Registering again as process ", serverParameters.ProcessID)
+
+	err := g.mBackend.InitSDK(serverParameters)
+	if err == nil {
+		/// IDLE first. A game session may come right after ProcessReady
+		g.WriteStateFile("IDLE")
+		err = g.mBackend.ProcessReady(processParameters)
+	}
+
+	g.mLock.Lock()
+	defer g.mLock.Unlock()
+
+	if err != nil {
+		myLogger.Print("[GAMELIFT] Registering again failed. Exiting: ", err.Error())
+		g.TerminateGameSession(1)
+		return
+	}
+
+	g.mActivated = true
+}
+
+// StopTimers stops whatever could still act on a finished game session
+func (gs *GameSession) StopTimers() {
+	gs.StopTurnTimer()
+
+	if gs.mPauseTimer != nil {
+		gs.mPauseTimer.Stop()
+		gs.mPauseTimer = nil
+	}
+
+	for _, sd := range gs.mDisconnected {
+		if sd != nil && sd.mTimer != nil {
+			sd.mTimer.Stop()
+		}
+	}
+}
+
+// DisconnectSpectators sends away the spectators still watching
+func (gs *GameSession) DisconnectSpectators() {
+	gs.mSpectatorLock.Lock()
+	players := make([]GamePlayer, 0, len(gs.mSpectators))
+	for _, sp := range gs.mSpectators {
+		players = append(players, sp.mPlayer)
+	}
+	gs.mSpectatorLock.Unlock()
+
+	for _, p := range players {
+		p.Disconnect(DR_LOGOUT)
+	}
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// ConnectionStatus is reported to the other player and spectators with PKT_SC_CONNECTION_STATUS
+type ConnectionStatus byte
+
+const (
+	CONN_DISCONNECTED ConnectionStatus = 1 // socket dropped, seat kept for the reconnect grace time
+	CONN_RECONNECTING ConnectionStatus = 2 // a new connection presented the player session
+	CONN_RECONNECTED  ConnectionStatus = 3
+	CONN_TIMED_OUT    ConnectionStatus = 4 // grace time is over. The player has left
+)
+
+// SeatDisconnect is the reconnect bookkeeping of a seat whose player dropped mid-game
+type SeatDisconnect struct {
+	mPlayer   *PlayerSession // dropped connection, still seated
+	mDeadline time.Time
+	mTimer    *time.Timer
+}
+
+// SeatDisconnected keeps the seat of psess for grace. psess leaves the game if it doesn't reconnect in time.
+func (gs *GameSession) SeatDisconnected(psess *PlayerSession, grace time.Duration) {
+	st := gs.SeatOf(psess)
+	if st == STONE_NONE {
+		return
+	}
+
+	sd := &SeatDisconnect{
+		mPlayer:   psess,
+		mDeadline: time.Now().Add(grace),
+	}
+	sd.mTimer = time.AfterFunc(grace, func() {
+		gs.mGameLiftManager.mLock.Lock()
+		defer gs.mGameLiftManager.mLock.Unlock()
+
+		if gs.mDisconnected[st] == sd {
+			gs.SeatTimedOut(st)
+		}
+	})
+	gs.mDisconnected[st] = sd
+
+	myLogger.Printf("[RECONNECT] %s disconnected. Waiting %s for reconnect\n", psess.GetPlayerSessionId(), grace)
+	gs.BroadcastConnectionStatus(st, CONN_DISCONNECTED)
+}
+
+// FindDisconnectedSeat returns the seat waiting for playerSessionId to reconnect, if any
+func (gs *GameSession) FindDisconnectedSeat(playerSessionId string) StoneType {
+	for _, st := range []StoneType{STONE_BLACK, STONE_WHITE} {
+		if sd := gs.mDisconnected[st]; sd != nil && sd.mPlayer.mPlayerSessionId == playerSessionId {
+			return st
+		}
+	}
+	return STONE_NONE
+}
+
+// SeatReconnected hands the seat over to the new connection and brings it up to date
+func (gs *GameSession) SeatReconnected(st StoneType, psess *PlayerSession) {
+	sd := gs.mDisconnected[st]
+	if sd == nil {
+		return
+	}
+
+	sd.mTimer.Stop()
+	gs.mDisconnected[st] = nil
+
+	psess.mPlayerSessionId = sd.mPlayer.mPlayerSessionId
+	psess.mPlayerName = sd.mPlayer.mPlayerName
+	psess.mScore = sd.mPlayer.mScore
+	psess.mTeam = sd.mPlayer.mTeam
+	psess.mLastMoveSeq = sd.mPlayer.mLastMoveSeq
+
+	if st == STONE_BLACK {
+		gs.mPlayerBlack = psess
+	} else {
+		gs.mPlayerWhite = psess
+	}
+
+	myLogger.Printf("[RECONNECT] %s reconnected from %s\n", psess.GetPlayerSessionId(), psess.mClientAddr.String())
+	gs.BroadcastConnectionStatus(st, CONN_RECONNECTED)
+
+	if gs.mBoardStatus != nil {
+		gs.SendGameStart(psess)
+		gs.SendGameStatus(psess)
+	}
+
+	// The other player may be away as well
+	other := STONE_BLACK
+	if st == STONE_BLACK {
+		other = STONE_WHITE
+	}
+	if gs.mDisconnected[other] != nil {
+		outPacket := gs.MakeConnectionStatusPacket(other, CONN_DISCONNECTED)
+		if false == psess.PostSend(outPacket, len(outPacket)) {
+			psess.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+func (gs *GameSession) SeatTimedOut(st StoneType) {
+	sd := gs.mDisconnected[st]
+	if sd == nil {
+		return
+	}
+	gs.mDisconnected[st] = nil
+
+	myLogger.Printf("[RECONNECT] %s didn't reconnect in time\n", sd.mPlayer.GetPlayerSessionId())
+	gs.BroadcastConnectionStatus(st, CONN_TIMED_OUT)
+
+	gs.mGameLiftManager.RemovePlayerSession(sd.mPlayer, sd.mPlayer.mPlayerSessionId, WR_ABANDONMENT)
+	sd.mPlayer.mPlayerSessionId = ""
+}
+
+// GraceRemaining is how long the seat st is still kept. 0 if it isn't waiting for a reconnect.
+func (gs *GameSession) GraceRemaining(st StoneType) time.Duration {
+	sd := gs.mDisconnected[st]
+	if sd == nil {
+		return 0
+	}
+
+	remaining := time.Until(sd.mDeadline)
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// BroadcastConnectionStatus notifies the opponent of st and spectators
+func (gs *GameSession) BroadcastConnectionStatus(st StoneType, status ConnectionStatus) {
+	outPacket := gs.MakeConnectionStatusPacket(st, status)
+
+	if opponent := gs.Opponent(gs.PlayerOf(st)); opponent != nil && gs.mDisconnected[gs.SeatOf(opponent)] == nil {
+		if false == opponent.PostSend(outPacket, len(outPacket)) {
+			opponent.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+
+	gs.PublishToSpectators(outPacket, false)
+}
+
+func (gs *GameSession) MakeConnectionStatusPacket(st StoneType, status ConnectionStatus) []byte {
+	var size, ptype uint16
+
+	// ConnectionStatus message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// StoneType (1byte) seat of the player concerned
+	// ConnectionStatus (1byte)
+	// mGraceRemaining (4byte) milliseconds until the seat is given up, 0 unless disconnected or reconnecting
+	var outPacket [2 + 2 + 1 + 1 + 4]byte
+
+	size = 2 + 2 + 1 + 1 + 4
+	ptype = uint16(PKT_SC_CONNECTION_STATUS)
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	outPacket[4] = byte(st)
+	outPacket[5] = byte(status)
+	binary.LittleEndian.PutUint32(outPacket[6:], uint32(gs.GraceRemaining(st)/time.Millisecond))
+
+	return outPacket[0:size]
+}
+
+// PlayerDisconnected decides whether a dropped player keeps the seat for a reconnect.
+// Returns false when the player should leave right away.
+func (g *GameLiftManager) PlayerDisconnected(psess *PlayerSession, dr DisconnectReason) bool {
+	gs := g.mGameSession
+
+	if g.mReconnectGrace <= 0 || dr == DR_LOGOUT || dr == DR_UNAUTH || gs == nil {
+		return false
+	}
+
+	if gs.mGameStatus != GS_STARTED && gs.mGameStatus != GS_PAUSED {
+		return false
+	}
+
+	if gs.SeatOf(psess) == STONE_NONE {
+		return false
+	}
+
+	gs.SeatDisconnected(psess, g.mReconnectGrace)
+	return true
+}
+
+// ReconnectPlayerSession lets a new connection take back the seat of a dropped one with the same player session.
+// The player session was accepted with the first connection already.
+func (g *GameLiftManager) ReconnectPlayerSession(psess *PlayerSession, playerSessionId string) bool {
+	gs := g.mGameSession
+	if gs == nil {
+		return false
+	}
+
+	st := gs.FindDisconnectedSeat(playerSessionId)
+	if st == STONE_NONE {
+		return false
+	}
+
+	gs.BroadcastConnectionStatus(st, CONN_RECONNECTING)
+
+	if _, err := g.DescribePlayerSessions(playerSessionId); err != nil {
+		gs.BroadcastConnectionStatus(st, CONN_DISCONNECTED)
+		return false
+	}
+
+	gs.SeatReconnected(st, psess)
+	return true
+}
@@ -0,0 +1,369 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayPlayer sits at the board during a replay. It has no connection and drops every packet.
+type ReplayPlayer struct {
+	mPlayerName string
+	mScore      int
+}
+
+func (rp *ReplayPlayer) GetPlayerSessionId() string            { return "replay-" + rp.mPlayerName }
+func (rp *ReplayPlayer) GetPlayerName() string                 { return rp.mPlayerName }
+func (rp *ReplayPlayer) GetPlayerScore() int                   { return rp.mScore }
+func (rp *ReplayPlayer) HasCapability(c ClientCapability) bool { return c == CAP_DELTA_BOARD }
+func (rp *ReplayPlayer) PostSend(data []byte, len int) bool    { return true }
+func (rp *ReplayPlayer) Disconnect(dr DisconnectReason)        {}
+
+// RecordedGame is one game tree loaded from an SGF file written by WriteGameRecord
+type RecordedGame struct {
+	mBlackName  string
+	mWhiteName  string
+	mBlackScore int
+	mWhiteScore int
+	mBoardSize  int
+	mRule       RuleVariant
+	mResult     string // SGF RE value. "B+", "W+", "B+F", "W+F", "0" or "?"
+	mMoves      []MoveRecord
+}
+
+type sgfNode map[string][]string
+
+// ParseSGF reads every game tree of an SGF collection. Variations are not supported.
+func ParseSGF(text string) ([]RecordedGame, error) {
+	var games []RecordedGame
+	var nodes []sgfNode
+	var depth int
+
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '(':
+			if depth > 0 {
+				return nil, fmt.Errorf("variations are not supported (offset %d)", i)
+			}
+			depth++
+			nodes = nil
+
+		case c == ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced ')' (offset %d)", i)
+			}
+			depth--
+			game, err := makeRecordedGame(nodes)
+			if err != nil {
+				return nil, err
+			}
+			games = append(games, game)
+
+		case c == ';':
+			nodes = append(nodes, sgfNode{})
+
+		case c >= 'A' && c <= 'Z':
+			if len(nodes) == 0 {
+				return nil, fmt.Errorf("property outside of a node (offset %d)", i)
+			}
+			start := i
+			for i < len(text) && text[i] >= 'A' && text[i] <= 'Z' {
+				i++
+			}
+			ident := text[start:i]
+
+			for {
+				for i < len(text) && strings.ContainsRune(" \t\r\n", rune(text[i])) {
+					i++
+				}
+				if i >= len(text) || text[i] != '[' {
+					break
+				}
+				var sb strings.Builder
+				for i++; i < len(text) && text[i] != ']'; i++ {
+					if text[i] == '\\' && i+1 < len(text) {
+						i++
+					}
+					sb.WriteByte(text[i])
+				}
+				if i >= len(text) {
+					return nil, fmt.Errorf("unterminated value of %s", ident)
+				}
+				i++
+				nodes[len(nodes)-1][ident] = append(nodes[len(nodes)-1][ident], sb.String())
+			}
+			i--
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("unterminated game tree")
+	}
+
+	return games, nil
+}
+
+func makeRecordedGame(nodes []sgfNode) (RecordedGame, error) {
+	var game RecordedGame
+
+	if len(nodes) == 0 {
+		return game, fmt.Errorf("empty game tree")
+	}
+
+	root := nodes[0]
+	if gm := root.Get("GM"); gm != "" && gm != "4" {
+		return game, fmt.Errorf("not a gomoku record: GM[%s]", gm)
+	}
+
+	game.mBoardSize = BOARD_SIZE
+	if sz := root.Get("SZ"); sz != "" {
+		game.mBoardSize, _ = strconv.Atoi(sz)
+	}
+	if ru := root.Get("RU"); ru != "" {
+		rule, err := ParseRuleVariant(ru)
+		if err != nil {
+			return game, err
+		}
+		game.mRule = rule
+	}
+	game.mBlackName = root.Get("PB")
+	game.mWhiteName = root.Get("PW")
+	game.mBlackScore, _ = strconv.Atoi(root.Get("BR"))
+	game.mWhiteScore, _ = strconv.Atoi(root.Get("WR"))
+	game.mResult = root.Get("RE")
+
+	for _, node := range nodes[1:] {
+		var st StoneType
+		var coord string
+
+		if coord = node.Get("B"); coord != "" {
+			st = STONE_BLACK
+		} else if coord = node.Get("W"); coord != "" {
+			st = STONE_WHITE
+		} else {
+			continue
+		}
+
+		if len(coord) != 2 {
+			return game, fmt.Errorf("bad move coordinate [%s]", coord)
+		}
+
+		thinkTime, _ := strconv.ParseFloat(node.Get("MT"), 64)
+		game.mMoves = append(game.mMoves, MoveRecord{
+			mMoveNumber: uint32(len(game.mMoves) + 1),
+			mXpos:       int(coord[0] - 'a'),
+			mYpos:       int(coord[1] - 'a'),
+			mStone:      st,
+			mThinkTime:  time.Duration(thinkTime * float64(time.Second)),
+		})
+	}
+
+	return game, nil
+}
+
+func (n sgfNode) Get(ident string) string {
+	if values := n[ident]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ExpectedStatus maps the recorded SGF result to the GameStatus the rules should produce.
+// A forfeit ends the game like a win on the board does.
+func (rg *RecordedGame) ExpectedStatus() GameStatus {
+	switch rg.mResult {
+	case "B+", "B+F":
+		return GS_GAME_OVER_BLACK_WIN
+	case "W+", "W+F":
+		return GS_GAME_OVER_WHITE_WIN
+	case "0":
+		return GS_GAME_OVER_DRAW
+	}
+	return GS_STARTED
+}
+
+func (gs *GameSession) PrintBoard(w io.Writer) {
+	fmt.Fprint(w, "   ")
+	for x := 0; x < len(gs.mBoardStatus); x++ {
+		fmt.Fprintf(w, " %c", 'a'+x)
+	}
+	fmt.Fprintln(w)
+
+	for y := 0; y < len(gs.mBoardStatus); y++ {
+		fmt.Fprintf(w, "%3d", y+1)
+		for x := 0; x < len(gs.mBoardStatus); x++ {
+			switch StoneType(gs.mBoardStatus[x][y]) {
+			case STONE_BLACK:
+				fmt.Fprint(w, " X")
+			case STONE_WHITE:
+				fmt.Fprint(w, " O")
+			default:
+				fmt.Fprint(w, " .")
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// ReplayGame feeds the recorded moves through GameSession.PutStone and checks the outcome against the record.
+func ReplayGame(rg *RecordedGame, out io.Writer) error {
+	config := DefaultSessionConfig()
+	config.mBoardSize = rg.mBoardSize
+	config.mRule = rg.mRule
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	g := &GameLiftManager{}
+	black := &ReplayPlayer{mPlayerName: rg.mBlackName, mScore: rg.mBlackScore}
+	white := &ReplayPlayer{mPlayerName: rg.mWhiteName, mScore: rg.mWhiteScore}
+
+	gs := &GameSession{
+		mPlayerBlack: nil,
+		mPlayerWhite: nil,
+		mGameStatus:  GS_NOT_STARTED,
+		mCurrentTurn: STONE_NONE,
+		mConfig:      config,
+
+		mGameLiftManager: g,
+	}
+	g.mGameSession = gs
+
+	gs.PlayerEnter(black)
+	gs.PlayerEnter(white)
+	gs.BroadcastGameStart()
+
+	for _, m := range rg.mMoves {
+		var player GamePlayer = black
+		if m.mStone == STONE_WHITE {
+			player = white
+		}
+
+		ec := gs.PutStone(player, m.mXpos, m.mYpos)
+
+		if out != nil {
+			fmt.Fprintf(out, "Move %d: %s %s\n", m.mMoveNumber, player.GetPlayerName(), psnCoord(m.mXpos, m.mYpos))
+			gs.PrintBoard(out)
+		}
+
+		if ec != EC_NONE {
+			return fmt.Errorf("move %d %s rejected with error code %d", m.mMoveNumber, psnCoord(m.mXpos, m.mYpos), ec)
+		}
+	}
+
+	/// The loser of a forfeit left a game still running on the board
+	if (rg.mResult == "B+F" || rg.mResult == "W+F") && gs.mGameStatus == GS_STARTED {
+		var loser GamePlayer = white
+		if rg.mResult == "W+F" {
+			loser = black
+		}
+		gs.PlayerLeave(loser, WR_ABANDONMENT)
+	}
+
+	if gs.mGameStatus != rg.ExpectedStatus() {
+		return fmt.Errorf("recorded result %s but the rules produce game status %d", rg.mResult, gs.mGameStatus)
+	}
+
+	return nil
+}
+
+// RunReplay implements the replay subcommand:
+//
+//	gomoku-in-go replay [-quiet] [-v] <record.sgf | directory>...
+//
+// It returns the process exit code, non-zero when any game doesn't replay to its recorded result.
+func RunReplay(args []string) int {
+	var quiet, verbose bool
+
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.BoolVar(&quiet, "quiet", false, "don't print the board after each move")
+	fs.BoolVar(&verbose, "v", false, "print game server logs to stderr")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: gomoku-in-go replay [-quiet] [-v] <record.sgf | directory>...")
+		return 2
+	}
+
+	if verbose {
+		myLogger = log.New(os.Stderr, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
+	} else {
+		myLogger = log.New(io.Discard, "", 0)
+	}
+
+	var out io.Writer = os.Stdout
+	if quiet {
+		out = nil
+	}
+
+	var files []string
+	for _, arg := range fs.Args() {
+		matches, _ := filepath.Glob(filepath.Join(arg, "*.sgf"))
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			files = append(files, matches...)
+		} else {
+			files = append(files, arg)
+		}
+	}
+
+	var verified, failed int
+	for _, file := range files {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed++
+			continue
+		}
+
+		games, err := ParseSGF(string(text))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", file, err)
+			failed++
+			continue
+		}
+
+		for i := range games {
+			if out != nil {
+				fmt.Fprintf(out, "=== %s game %d: %s vs %s\n", file, i+1, games[i].mBlackName, games[i].mWhiteName)
+			}
+
+			if err := ReplayGame(&games[i], out); err != nil {
+				fmt.Fprintf(os.Stderr, "MISMATCH %s game %d: %s\n", file, i+1, err)
+				failed++
+			} else {
+				verified++
+			}
+		}
+	}
+
+	fmt.Printf("%d games verified, %d mismatched\n", verified, failed)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
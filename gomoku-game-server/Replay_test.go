@@ -0,0 +1,137 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSGF(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantErr   bool
+		games     int
+		boardSize int
+		rule      RuleVariant
+		black     string
+		result    string
+		moves     []MoveRecord
+	}{
+		{
+			name:      "game record",
+			text:      "(;FF[4]GM[4]SZ[15]RU[standard]PB[alice]PW[bob]BR[1200]WR[1100]RE[B+];B[hh]MT[1.5];W[hi])",
+			games:     1,
+			boardSize: 15,
+			rule:      RULE_STANDARD,
+			black:     "alice",
+			result:    "B+",
+			moves: []MoveRecord{
+				{mMoveNumber: 1, mXpos: 7, mYpos: 7, mStone: STONE_BLACK, mThinkTime: 1500 * time.Millisecond},
+				{mMoveNumber: 2, mXpos: 7, mYpos: 8, mStone: STONE_WHITE},
+			},
+		},
+		{
+			name:      "defaults",
+			text:      "(;GM[4])",
+			games:     1,
+			boardSize: BOARD_SIZE,
+			rule:      RULE_FREESTYLE,
+		},
+		{
+			name:      "escaped value and whitespace",
+			text:      "(;GM[4]PB [a\\]b]\n;B[aa])",
+			games:     1,
+			boardSize: BOARD_SIZE,
+			black:     "a]b",
+			moves:     []MoveRecord{{mMoveNumber: 1, mStone: STONE_BLACK}},
+		},
+		{name: "collection", text: "(;GM[4])(;GM[4])", games: 2, boardSize: BOARD_SIZE},
+		{name: "empty", text: "", games: 0},
+		{name: "variation", text: "(;GM[4](;B[aa]))", wantErr: true},
+		{name: "unbalanced", text: "(;GM[4]))", wantErr: true},
+		{name: "unterminated tree", text: "(;GM[4]", wantErr: true},
+		{name: "unterminated value", text: "(;GM[4", wantErr: true},
+		{name: "property outside of a node", text: "(GM[4])", wantErr: true},
+		{name: "empty tree", text: "()", wantErr: true},
+		{name: "not gomoku", text: "(;GM[1])", wantErr: true},
+		{name: "unknown rule", text: "(;GM[4]RU[renju])", wantErr: true},
+		{name: "bad coordinate", text: "(;GM[4];B[a])", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := ParseSGF(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSGF error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(games) != tt.games {
+				t.Fatalf("%d games, want %d", len(games), tt.games)
+			}
+			if tt.games == 0 {
+				return
+			}
+
+			game := games[0]
+			if game.mBoardSize != tt.boardSize || game.mRule != tt.rule || game.mBlackName != tt.black || game.mResult != tt.result {
+				t.Errorf("board %d rule %s black %q result %q, want %d %s %q %q",
+					game.mBoardSize, game.mRule, game.mBlackName, game.mResult, tt.boardSize, tt.rule, tt.black, tt.result)
+			}
+			if len(game.mMoves) != len(tt.moves) {
+				t.Fatalf("%d moves, want %d", len(game.mMoves), len(tt.moves))
+			}
+			for i, m := range game.mMoves {
+				if m != tt.moves[i] {
+					t.Errorf("move %d %+v, want %+v", i+1, m, tt.moves[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReplayGame(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr bool
+	}{
+		{"black wins", "(;GM[4]RE[B+];B[aa];W[ab];B[ba];W[bb];B[ca];W[cb];B[da];W[db];B[ea])", false},
+		{"forfeit", "(;GM[4]RE[W+F];B[aa];W[ab])", false},
+		{"forfeit after five", "(;GM[4]RE[W+F];B[aa];W[ab];B[ba];W[bb];B[ca];W[cb];B[da];W[db];B[ea])", true},
+		{"wrong result", "(;GM[4]RE[W+];B[aa];W[ab];B[ba];W[bb];B[ca];W[cb];B[da];W[db];B[ea])", true},
+		{"occupied", "(;GM[4]RE[?];B[aa];W[aa])", true},
+		{"out of turn", "(;GM[4]RE[?];B[aa];B[ab])", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			games, err := ParseSGF(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ReplayGame(&games[0], nil); (err != nil) != tt.wantErr {
+				t.Errorf("ReplayGame error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
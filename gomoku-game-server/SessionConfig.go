@@ -0,0 +1,160 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	MIN_BOARD_SIZE   = 9 // BOARD_SIZE is the default and the largest
+	DEFAULT_K_FACTOR = 100
+	MAX_K_FACTOR     = 400
+)
+
+// Game property keys. The same keys may be given in GameSessionData as a JSON object,
+// e.g. {"boardSize" : 15, "ranked" : false}. Game properties win when both have one.
+const (
+	PROP_BOARD_SIZE   = "boardSize"   // 9 to 19
+	PROP_RULE         = "rule"        // freestyle or standard
+	PROP_TIME_CONTROL = "timeControl" // "none", or main time and increment in seconds, e.g. "300+5"
+	PROP_RANKED       = "ranked"      // true or false
+	PROP_K_FACTOR     = "kFactor"     // Elo K-factor, 1 to 400
+	PROP_SERIES_GAME  = "seriesGame"  // 1-based game number within a series, see ColorAssignment
+)
+
+type RuleVariant uint8
+
+const (
+	RULE_FREESTYLE RuleVariant = 0 // five or more in a row wins
+	RULE_STANDARD  RuleVariant = 1 // exactly five in a row wins. An overline doesn't
+)
+
+var ruleVariantNames = []string{"freestyle", "standard"}
+
+func (r RuleVariant) String() string {
+	if int(r) < len(ruleVariantNames) {
+		return ruleVariantNames[r]
+	}
+	return "unknown"
+}
+
+func ParseRuleVariant(name string) (RuleVariant, error) {
+	for i, n := range ruleVariantNames {
+		if n == name {
+			return RuleVariant(i), nil
+		}
+	}
+	return RULE_FREESTYLE, fmt.Errorf("unknown rule %q. One of %s", name, strings.Join(ruleVariantNames, ", "))
+}
+
+// SessionConfig is how a game session is played, taken from its game properties
+type SessionConfig struct {
+	mBoardSize   int
+	mRule        RuleVariant
+	mTimeControl TimeControl
+	mRanked      bool
+	mKFactor     int
+	mSeriesGame  int
+}
+
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfig{
+		mBoardSize:  BOARD_SIZE,
+		mRule:       RULE_FREESTYLE,
+		mRanked:     true,
+		mKFactor:    DEFAULT_K_FACTOR,
+		mSeriesGame: 1,
+	}
+}
+
+// ParseSessionConfig starts from DefaultSessionConfig and fails on any property it can't use,
+// rather than playing a match other than the one asked for. Other keys are left to others.
+func ParseSessionConfig(gameProperties map[string]string, gameSessionData string) (SessionConfig, error) {
+	config := DefaultSessionConfig()
+	props := make(map[string]string)
+
+	if strings.TrimSpace(gameSessionData) != "" {
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(gameSessionData), &data); err != nil {
+			return config, fmt.Errorf("GameSessionData isn't a JSON object: %s", err)
+		}
+		for key, value := range data {
+			props[key] = fmt.Sprint(value)
+		}
+	}
+	for key, value := range gameProperties {
+		props[key] = value
+	}
+
+	var err error
+	if value, ok := props[PROP_BOARD_SIZE]; ok {
+		if config.mBoardSize, err = strconv.Atoi(value); err != nil {
+			return config, fmt.Errorf("%s %q isn't a number", PROP_BOARD_SIZE, value)
+		}
+	}
+	if value, ok := props[PROP_RULE]; ok {
+		if config.mRule, err = ParseRuleVariant(value); err != nil {
+			return config, err
+		}
+	}
+	if value, ok := props[PROP_TIME_CONTROL]; ok {
+		if config.mTimeControl, err = ParseTimeControl(value); err != nil {
+			return config, err
+		}
+	}
+	if value, ok := props[PROP_RANKED]; ok {
+		if config.mRanked, err = strconv.ParseBool(value); err != nil {
+			return config, fmt.Errorf("%s %q isn't true or false", PROP_RANKED, value)
+		}
+	}
+	if value, ok := props[PROP_K_FACTOR]; ok {
+		if config.mKFactor, err = strconv.Atoi(value); err != nil {
+			return config, fmt.Errorf("%s %q isn't a number", PROP_K_FACTOR, value)
+		}
+	}
+	if value, ok := props[PROP_SERIES_GAME]; ok {
+		if config.mSeriesGame, err = strconv.Atoi(value); err != nil {
+			return config, fmt.Errorf("%s %q isn't a number", PROP_SERIES_GAME, value)
+		}
+	}
+
+	return config, config.Validate()
+}
+
+func (c *SessionConfig) Validate() error {
+	if c.mBoardSize < MIN_BOARD_SIZE || c.mBoardSize > BOARD_SIZE {
+		return fmt.Errorf("%s %d out of range %d-%d", PROP_BOARD_SIZE, c.mBoardSize, MIN_BOARD_SIZE, BOARD_SIZE)
+	}
+	if c.mKFactor < 1 || c.mKFactor > MAX_K_FACTOR {
+		return fmt.Errorf("%s %d out of range 1-%d", PROP_K_FACTOR, c.mKFactor, MAX_K_FACTOR)
+	}
+	if c.mSeriesGame < 1 {
+		return fmt.Errorf("%s %d must be 1 or more", PROP_SERIES_GAME, c.mSeriesGame)
+	}
+	return nil
+}
+
+func (c *SessionConfig) String() string {
+	return fmt.Sprintf("board %dx%d, rule %s, time control %s, ranked %t, K-factor %d, series game %d",
+		c.mBoardSize, c.mBoardSize, c.mRule, c.mTimeControl, c.mRanked, c.mKFactor, c.mSeriesGame)
+}
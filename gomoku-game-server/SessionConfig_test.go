@@ -0,0 +1,85 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseSessionConfig(t *testing.T) {
+	tests := []struct {
+		name            string
+		gameProperties  map[string]string
+		gameSessionData string
+		want            SessionConfig
+		wantErr         bool
+	}{
+		{
+			name: "defaults",
+			want: DefaultSessionConfig(),
+		},
+		{
+			name: "game properties",
+			gameProperties: map[string]string{
+				PROP_BOARD_SIZE: "15", PROP_RULE: "standard", PROP_TIME_CONTROL: "300+5",
+				PROP_RANKED: "false", PROP_K_FACTOR: "32", PROP_SERIES_GAME: "2", "unrelated": "x",
+			},
+			want: SessionConfig{mBoardSize: 15, mRule: RULE_STANDARD, mTimeControl: TimeControl{5 * time.Minute, 5 * time.Second},
+				mRanked: false, mKFactor: 32, mSeriesGame: 2},
+		},
+		{
+			name:            "game session data",
+			gameSessionData: `{"boardSize" : 9, "ranked" : false, "timeControl" : "60"}`,
+			want: SessionConfig{mBoardSize: 9, mRule: RULE_FREESTYLE, mTimeControl: TimeControl{mMainTime: time.Minute},
+				mRanked: false, mKFactor: DEFAULT_K_FACTOR, mSeriesGame: 1},
+		},
+		{
+			name:            "game properties win",
+			gameProperties:  map[string]string{PROP_BOARD_SIZE: "13"},
+			gameSessionData: `{"boardSize" : 9, "kFactor" : 50}`,
+			want:            SessionConfig{mBoardSize: 13, mRule: RULE_FREESTYLE, mRanked: true, mKFactor: 50, mSeriesGame: 1},
+		},
+		{name: "blank game session data", gameSessionData: "  ", want: DefaultSessionConfig()},
+		{name: "game session data not JSON", gameSessionData: "boardSize=9", wantErr: true},
+		{name: "game session data not an object", gameSessionData: `[9]`, wantErr: true},
+		{name: "board size not a number", gameProperties: map[string]string{PROP_BOARD_SIZE: "big"}, wantErr: true},
+		{name: "board size fraction", gameSessionData: `{"boardSize" : 9.5}`, wantErr: true},
+		{name: "board too small", gameProperties: map[string]string{PROP_BOARD_SIZE: "8"}, wantErr: true},
+		{name: "board too large", gameProperties: map[string]string{PROP_BOARD_SIZE: "20"}, wantErr: true},
+		{name: "unknown rule", gameProperties: map[string]string{PROP_RULE: "renju"}, wantErr: true},
+		{name: "bad time control", gameProperties: map[string]string{PROP_TIME_CONTROL: "5m"}, wantErr: true},
+		{name: "ranked not a bool", gameProperties: map[string]string{PROP_RANKED: "yes please"}, wantErr: true},
+		{name: "K-factor zero", gameProperties: map[string]string{PROP_K_FACTOR: "0"}, wantErr: true},
+		{name: "K-factor too large", gameProperties: map[string]string{PROP_K_FACTOR: "401"}, wantErr: true},
+		{name: "series game zero", gameProperties: map[string]string{PROP_SERIES_GAME: "0"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := ParseSessionConfig(tt.gameProperties, tt.gameSessionData)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSessionConfig error %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && config != tt.want {
+				t.Errorf("ParseSessionConfig = %s, want %s", config.String(), tt.want.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	SHUTDOWN_FLUSH_TIME    = 5 * time.Second        // kept at the end of the deadline for sending results and leaving GameLift
+	SHUTDOWN_POLL_INTERVAL = 250 * time.Millisecond // how often Shutdown checks whether the game is over
+	SHUTDOWN_CLOSE_DELAY   = time.Second            // lets clients read the game result before the connections are reset
+	ADJUDICATION_BUDGET    = time.Second            // engine search time for adjudicating a game
+)
+
+// HandleSignals shuts down in order on SIGTERM, e.g. when ECS stops the task, and on Ctrl-C.
+// SIGUSR1 starts draining.
+func (g *GameLiftManager) HandleSignals() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt, syscall.SIGUSR1)
+
+	go func() {
+		for sig := range signals {
+			if sig == syscall.SIGUSR1 {
+				go g.Drain(sig.String())
+				continue
+			}
+			g.Shutdown(sig.String())
+		}
+	}()
+}
+
+func (g *GameLiftManager) IsShuttingDown() bool {
+	return atomic.LoadInt32(&g.mShuttingDown) != 0
+}
+
+// ShutdownDeadline is mShutdownTimeout from now, or the termination time from GameLift if that is earlier
+func (g *GameLiftManager) ShutdownDeadline() time.Time {
+	now := time.Now()
+	deadline := now.Add(g.mShutdownTimeout)
+
+	terminationTime, err := g.mBackend.GetTerminationTime()
+	if err != nil {
+		myLogger.Print("[SHUTDOWN] No termination time: ", err.Error())
+	} else if terminationTime.After(now) && terminationTime.Before(deadline) {
+		deadline = terminationTime
+	}
+
+	return deadline
+}
+
+// Shutdown ends the process in order. The players are told the countdown, a running game goes on until
+// SHUTDOWN_FLUSH_TIME before the deadline and is adjudicated then, the results are sent, listener and
+// connections are closed, and finally ProcessEnding and Destroy are called. Whatever is still left
+// at the deadline is cut short.
+func (g *GameLiftManager) Shutdown(reason string) {
+	if !atomic.CompareAndSwapInt32(&g.mShuttingDown, 0, 1) {
+		return
+	}
+
+	deadline := g.ShutdownDeadline()
+	myLogger.Printf("[SHUTDOWN] %s. Ending process by %s\n", reason, deadline.Format(time.RFC3339))
+
+	time.AfterFunc(time.Until(deadline), func() {
+		myLogger.Print("[SHUTDOWN] Deadline passed. Exiting now")
+		g.mBackend.ProcessEnding()
+		os.Exit(1)
+	})
+
+	adjudicateAt := deadline.Add(-SHUTDOWN_FLUSH_TIME)
+
+	g.mLock.Lock()
+	g.StopBackfill()
+	gs := g.mGameSession
+	if gs != nil {
+		gs.BroadcastServerShutdown(deadline, adjudicateAt)
+	}
+	g.mLock.Unlock()
+
+	if gs != nil {
+		gs.PlayOut(adjudicateAt)
+	}
+
+	// Let the post-game analysis finish and the results go out first
+	g.mPendingResults.Wait()
+	g.SendGameResultToSQS()
+
+	if g.mIocpManager != nil {
+		g.mIocpManager.StopAccept()
+	}
+	if gs != nil {
+		time.Sleep(SHUTDOWN_CLOSE_DELAY)
+	}
+
+	/// No registering again or reconnecting after ProcessEnding. A running one finishes first
+	g.mSDKLock.Lock()
+
+	/// kept until the exit, so that the disconnected players don't leave the game through the SDK anymore
+	g.mLock.Lock()
+
+	if gs != nil {
+		gs.StopTimers()
+		gs.DisconnectSpectators()
+		for _, p := range []GamePlayer{gs.mPlayerBlack, gs.mPlayerWhite} {
+			if p != nil {
+				p.Disconnect(DR_SERVER_SHUTDOWN)
+			}
+		}
+	}
+
+	if err := g.mBackend.ProcessEnding(); err != nil {
+		myLogger.Print("[SHUTDOWN] ProcessEnding Fail: ", err.Error())
+	}
+	g.mActivated = false
+
+	if err := g.mBackend.Destroy(); err != nil {
+		myLogger.Print("[SHUTDOWN] Destroy Fail: ", err.Error())
+	}
+
+	myLogger.Print("[SHUTDOWN] Done")
+	os.Exit(0)
+}
+
+// PlayOut waits for a running game to end by itself and adjudicates it at adjudicateAt
+func (gs *GameSession) PlayOut(adjudicateAt time.Time) {
+	for !gs.PlayOutStep(adjudicateAt) {
+		time.Sleep(SHUTDOWN_POLL_INTERVAL)
+	}
+}
+
+// PlayOutStep returns true once the game is over, adjudicating it if it's time
+func (gs *GameSession) PlayOutStep(adjudicateAt time.Time) bool {
+	gs.mGameLiftManager.mLock.Lock()
+	defer gs.mGameLiftManager.mLock.Unlock()
+
+	if gs.mGameStatus != GS_STARTED && gs.mGameStatus != GS_PAUSED {
+		return true
+	}
+	if time.Now().Before(adjudicateAt) {
+		return false
+	}
+
+	gs.Adjudicate()
+	return true
+}
+
+// Adjudicate ends a game that can't be played to the end. The side to move wins if the engine finds
+// a forced win for it and loses if it finds one against it. Anything else is a draw.
+// Adjudicated games are unrated.
+func (gs *GameSession) Adjudicate() {
+	/// the clocks don't matter anymore
+	gs.ResumeGame(time.Now())
+
+	if gs.mGameStatus != GS_STARTED {
+		return
+	}
+
+	toMove := gs.mCurrentTurn
+	result := NewEngine(AnalysisEngineConfig(gs.mConfig.mRule), 0).SearchWithBudget(gs.mBoardStatus, toMove, ADJUDICATION_BUDGET)
+
+	winner := STONE_NONE
+	if result.mScore > SCORE_WIN/2 {
+		winner = toMove
+	} else if result.mScore < -SCORE_WIN/2 {
+		winner = opponentOf(toMove)
+	}
+
+	myLogger.Printf("[SHUTDOWN] Adjudicating after %d moves. Engine score %d for %d to move\n", gs.mMoveNumber, result.mScore, toMove)
+
+	switch winner {
+	case STONE_BLACK:
+		gs.mGameStatus = GS_GAME_OVER_BLACK_WIN
+	case STONE_WHITE:
+		gs.mGameStatus = GS_GAME_OVER_WHITE_WIN
+	default:
+		gs.mGameStatus = GS_GAME_OVER_DRAW
+	}
+	gs.SendGameResult(winner, WR_ADJUDICATED)
+
+	gs.BroadcastGameStatus()
+	gs.BroadcastGameResult()
+}
+
+// BroadcastServerShutdown goes to the players and straight to the spectators, not through the delayed feed
+func (gs *GameSession) BroadcastServerShutdown(deadline time.Time, adjudicateAt time.Time) {
+	outPacket := gs.MakeServerShutdownPacket(deadline, adjudicateAt)
+
+	players := []GamePlayer{gs.mPlayerBlack, gs.mPlayerWhite}
+
+	gs.mSpectatorLock.Lock()
+	for _, sp := range gs.mSpectators {
+		players = append(players, sp.mPlayer)
+	}
+	gs.mSpectatorLock.Unlock()
+
+	for _, psess := range players {
+		if psess == nil {
+			continue
+		}
+		if false == psess.PostSend(outPacket, len(outPacket)) {
+			psess.Disconnect(DR_SENDBUFFER_ERROR)
+		}
+	}
+}
+
+func (gs *GameSession) MakeServerShutdownPacket(deadline time.Time, adjudicateAt time.Time) []byte {
+	var size, ptype uint16
+
+	// ServerShutdown message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// mTimeLeft (4byte) milliseconds until the server closes the connection
+	// mAdjudicateIn (4byte) milliseconds until a game still running is adjudicated. See WR_ADJUDICATED
+	var outPacket [2 + 2 + 4 + 4]byte
+
+	size = 2 + 2 + 4 + 4
+	ptype = uint16(PKT_SC_SERVER_SHUTDOWN)
+
+	now := time.Now()
+	var adjudicateIn time.Duration
+	if adjudicateAt.After(now) {
+		adjudicateIn = adjudicateAt.Sub(now)
+	}
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	binary.LittleEndian.PutUint32(outPacket[4:], uint32(deadline.Sub(now)/time.Millisecond))
+	binary.LittleEndian.PutUint32(outPacket[8:], uint32(adjudicateIn/time.Millisecond))
+
+	return outPacket[0:size]
+}
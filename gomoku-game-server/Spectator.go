@@ -0,0 +1,217 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const SPECTATOR_ROLE = "spectator"
+
+// Spectator is a read-only connection following a game.
+// Everything it receives goes through RunSpectatorFeed, which holds each update back for the spectator delay
+// so that a player can't get help from someone watching the live board (ghosting).
+type Spectator struct {
+	mPlayer GamePlayer
+	mNext   int           // index of the next frame in GameSession.mSpectatorFrames
+	mWake   chan struct{} // signaled when a frame is published or the spectator leaves
+	mClosed bool
+}
+
+type SpectatorFrame struct {
+	mTime   time.Time
+	mPacket []byte
+	mBoard  bool // full board snapshot. Late joiners only need the latest one
+}
+
+// IsSpectatorPlayerData tells if the player data of a GameLift player session asks for the spectator role.
+// Either the plain string "spectator" or a JSON object with "role" : "spectator".
+func IsSpectatorPlayerData(playerData string) bool {
+	var data struct {
+		Role string `json:"role"`
+	}
+
+	if err := json.Unmarshal([]byte(playerData), &data); err == nil {
+		return strings.EqualFold(data.Role, SPECTATOR_ROLE)
+	}
+
+	return strings.EqualFold(strings.TrimSpace(playerData), SPECTATOR_ROLE)
+}
+
+// CheckSpectatorToken compares in constant time. An empty expected token disables token access.
+func CheckSpectatorToken(expected string, token string) bool {
+	if expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+func (gs *GameSession) SpectatorCount() int {
+	gs.mSpectatorLock.Lock()
+	defer gs.mSpectatorLock.Unlock()
+
+	return len(gs.mSpectators)
+}
+
+// SpectatorEnter starts the delayed feed for psess. It first gets the game as it was a spectator delay ago,
+// i.e. the start info and the latest full board published before that, and then every later update.
+func (gs *GameSession) SpectatorEnter(psess GamePlayer) {
+	delay := gs.mGameLiftManager.mSpectatorDelay
+	cutoff := time.Now().Add(-delay)
+
+	sp := &Spectator{
+		mPlayer: psess,
+		mWake:   make(chan struct{}, 1),
+	}
+
+	gs.mSpectatorLock.Lock()
+
+	lastBoard := -1
+	for sp.mNext < len(gs.mSpectatorFrames) && !gs.mSpectatorFrames[sp.mNext].mTime.After(cutoff) {
+		if gs.mSpectatorFrames[sp.mNext].mBoard {
+			lastBoard = sp.mNext
+		}
+		sp.mNext++
+	}
+
+	var catchUp [][]byte
+	for i := 0; i < sp.mNext; i++ {
+		if !gs.mSpectatorFrames[i].mBoard || i == lastBoard {
+			catchUp = append(catchUp, gs.mSpectatorFrames[i].mPacket)
+		}
+	}
+
+	gs.mSpectators = append(gs.mSpectators, sp)
+	gs.mSpectatorLock.Unlock()
+
+	myLogger.Printf("[SPECTATOR] Enter %s (%d watching, delay %s)\n", psess.GetPlayerSessionId(), gs.SpectatorCount(), delay)
+
+	go gs.RunSpectatorFeed(sp, catchUp, delay)
+}
+
+func (gs *GameSession) SpectatorLeave(psess GamePlayer) {
+	gs.mSpectatorLock.Lock()
+	defer gs.mSpectatorLock.Unlock()
+
+	for i, sp := range gs.mSpectators {
+		if sp.mPlayer != psess {
+			continue
+		}
+
+		sp.mClosed = true
+		gs.mSpectators = append(gs.mSpectators[:i], gs.mSpectators[i+1:]...)
+
+		select {
+		case sp.mWake <- struct{}{}:
+		default:
+		}
+
+		myLogger.Printf("[SPECTATOR] Leave %s (%d watching)\n", psess.GetPlayerSessionId(), len(gs.mSpectators))
+		return
+	}
+}
+
+// PublishToSpectators queues a packet for all current and future spectators
+func (gs *GameSession) PublishToSpectators(packet []byte, board bool) {
+	frame := SpectatorFrame{
+		mTime:   time.Now(),
+		mPacket: append([]byte(nil), packet...),
+		mBoard:  board,
+	}
+
+	gs.mSpectatorLock.Lock()
+	defer gs.mSpectatorLock.Unlock()
+
+	gs.mSpectatorFrames = append(gs.mSpectatorFrames, frame)
+
+	for _, sp := range gs.mSpectators {
+		select {
+		case sp.mWake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (gs *GameSession) RunSpectatorFeed(sp *Spectator, catchUp [][]byte, delay time.Duration) {
+	for _, packet := range catchUp {
+		if false == sp.mPlayer.PostSend(packet, len(packet)) {
+			sp.mPlayer.Disconnect(DR_SENDBUFFER_ERROR)
+			return
+		}
+	}
+
+	for {
+		gs.mSpectatorLock.Lock()
+		if sp.mClosed {
+			gs.mSpectatorLock.Unlock()
+			return
+		}
+		if sp.mNext >= len(gs.mSpectatorFrames) {
+			gs.mSpectatorLock.Unlock()
+			<-sp.mWake
+			continue
+		}
+		frame := gs.mSpectatorFrames[sp.mNext]
+		sp.mNext++
+		gs.mSpectatorLock.Unlock()
+
+		if wait := time.Until(frame.mTime.Add(delay)); wait > 0 {
+			time.Sleep(wait)
+		}
+
+		gs.mSpectatorLock.Lock()
+		closed := sp.mClosed
+		gs.mSpectatorLock.Unlock()
+		if closed {
+			return
+		}
+
+		if false == sp.mPlayer.PostSend(frame.mPacket, len(frame.mPacket)) {
+			sp.mPlayer.Disconnect(DR_SENDBUFFER_ERROR)
+			return
+		}
+	}
+}
+
+func (gs *GameSession) MakeSpectateStartPacket() []byte {
+	var size, ptype uint16
+
+	// SpectateStart message structure
+	// mSize (2byte)
+	// mType (2byte)
+	// mBlackName (MAX_STRING_LEN byte)
+	// mWhiteName (MAX_STRING_LEN byte)
+	// mDelay (4byte) spectator delay in seconds
+	var outPacket [2 + 2 + MAX_STRING_LEN + MAX_STRING_LEN + 4]byte
+
+	size = 2 + 2 + MAX_STRING_LEN + MAX_STRING_LEN + 4
+	ptype = uint16(PKT_SC_SPECTATE_START)
+
+	binary.LittleEndian.PutUint16(outPacket[0:], size)
+	binary.LittleEndian.PutUint16(outPacket[2:], ptype)
+	copy(outPacket[4:4+MAX_STRING_LEN], gs.mPlayerBlack.GetPlayerName())
+	copy(outPacket[4+MAX_STRING_LEN:4+2*MAX_STRING_LEN], gs.mPlayerWhite.GetPlayerName())
+	binary.LittleEndian.PutUint32(outPacket[4+2*MAX_STRING_LEN:], uint32(gs.mGameLiftManager.mSpectatorDelay/time.Second))
+
+	return outPacket[0:size]
+}
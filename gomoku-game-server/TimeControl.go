@@ -0,0 +1,120 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeControl is a Fischer clock. Each player has mMainTime for the whole game and gets
+// mIncrement added after each of their moves. A player who runs out of time loses.
+type TimeControl struct {
+	mMainTime  time.Duration // 0 for no time limit
+	mIncrement time.Duration
+}
+
+func (tc TimeControl) Enabled() bool {
+	return tc.mMainTime > 0
+}
+
+func (tc TimeControl) String() string {
+	if !tc.Enabled() {
+		return "none"
+	}
+	return fmt.Sprintf("%d+%d", tc.mMainTime/time.Second, tc.mIncrement/time.Second)
+}
+
+func ParseTimeControl(value string) (TimeControl, error) {
+	var tc TimeControl
+
+	if value == "none" {
+		return tc, nil
+	}
+
+	mainTime, increment, hasIncrement := strings.Cut(value, "+")
+	main, err := strconv.Atoi(mainTime)
+	if err != nil || main <= 0 {
+		return tc, fmt.Errorf("bad main time in %q. Expecting \"none\" or seconds like \"300+5\"", value)
+	}
+	tc.mMainTime = time.Duration(main) * time.Second
+
+	if hasIncrement {
+		inc, err := strconv.Atoi(increment)
+		if err != nil || inc < 0 {
+			return tc, fmt.Errorf("bad increment in %q. Expecting \"none\" or seconds like \"300+5\"", value)
+		}
+		tc.mIncrement = time.Duration(inc) * time.Second
+	}
+
+	return tc, nil
+}
+
+// ArmTurnTimer starts counting down the time of the player on turn. Called whenever the turn
+// or the clock changes: game start, each move and resume.
+func (gs *GameSession) ArmTurnTimer() {
+	if !gs.mConfig.mTimeControl.Enabled() {
+		return
+	}
+
+	gs.StopTurnTimer()
+
+	moveNumber := gs.mMoveNumber
+	gs.mTurnTimer = time.AfterFunc(gs.mClock.Remaining(gs.mCurrentTurn, time.Now()), func() {
+		gs.mGameLiftManager.mLock.Lock()
+		defer gs.mGameLiftManager.mLock.Unlock()
+
+		gs.TurnTimedOut(moveNumber, time.Now())
+	})
+}
+
+func (gs *GameSession) StopTurnTimer() {
+	if gs.mTurnTimer != nil {
+		gs.mTurnTimer.Stop()
+		gs.mTurnTimer = nil
+	}
+}
+
+// TurnTimedOut ends the game with a loss for the player on turn, unless the game moved on meanwhile
+func (gs *GameSession) TurnTimedOut(moveNumber uint32, now time.Time) {
+	if gs.mGameStatus != GS_STARTED || gs.mMoveNumber != moveNumber {
+		return
+	}
+
+	loser := gs.mCurrentTurn
+	if gs.mClock.Remaining(loser, now) > 0 {
+		gs.ArmTurnTimer()
+		return
+	}
+
+	myLogger.Printf("[GAME OVER] %s ran out of time\n", gs.PlayerOf(loser).GetPlayerSessionId())
+
+	if loser == STONE_BLACK {
+		gs.mGameStatus = GS_GAME_OVER_WHITE_WIN
+		gs.SendGameResult(STONE_WHITE, WR_TIMEOUT)
+	} else {
+		gs.mGameStatus = GS_GAME_OVER_BLACK_WIN
+		gs.SendGameResult(STONE_BLACK, WR_TIMEOUT)
+	}
+
+	gs.BroadcastGameStatus()
+	gs.BroadcastGameResult()
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeControl(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    TimeControl
+		str     string // String() of the result
+		wantErr bool
+	}{
+		{value: "none", want: TimeControl{}, str: "none"},
+		{value: "300", want: TimeControl{mMainTime: 5 * time.Minute}, str: "300+0"},
+		{value: "300+5", want: TimeControl{mMainTime: 5 * time.Minute, mIncrement: 5 * time.Second}, str: "300+5"},
+		{value: "60+0", want: TimeControl{mMainTime: time.Minute}, str: "60+0"},
+		{value: "", wantErr: true},
+		{value: "None", wantErr: true},
+		{value: "0", wantErr: true},
+		{value: "-60", wantErr: true},
+		{value: "+5", wantErr: true},
+		{value: "300+", wantErr: true},
+		{value: "300+-5", wantErr: true},
+		{value: "300+5+5", wantErr: true},
+		{value: "5m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			tc, err := ParseTimeControl(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTimeControl(%q) error %v, want error %v", tt.value, err, tt.wantErr)
+			}
+			if !tt.wantErr && tc != tt.want {
+				t.Errorf("ParseTimeControl(%q) = %s, want %s", tt.value, tc, tt.want)
+			}
+			if !tt.wantErr && tc.String() != tt.str {
+				t.Errorf("String() = %q, want %q", tc.String(), tt.str)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTP API for test scripts. It plays the parts of CreateGameSession, CreatePlayerSession and
+// FlexMatch backfill that happen outside of the game server.
+//
+//	POST /game-sessions               {"ProcessId", "GameProperties", "MatchmakerData", "GameSessionData", "MaximumPlayerSessionCount"}
+//	POST /player-sessions             {"GameSessionId", "PlayerId", "PlayerData"}
+//	POST /update-game-session         {"GameSessionId", "UpdateReason", "BackfillTicketId", "MatchmakerData"}
+//	POST /terminate-process           {"ProcessId", "TerminationSeconds"}
+//	GET  /state
+
+type CreateGameSessionInput struct {
+	ProcessId                 string            `json:"ProcessId"` // any idle process when empty
+	Name                      string            `json:"Name"`
+	MaximumPlayerSessionCount int               `json:"MaximumPlayerSessionCount"`
+	GameProperties            map[string]string `json:"GameProperties"`
+	GameSessionData           string            `json:"GameSessionData"`
+	MatchmakerData            string            `json:"MatchmakerData"`
+}
+
+type CreatePlayerSessionInput struct {
+	GameSessionId string `json:"GameSessionId"`
+	PlayerId      string `json:"PlayerId"`
+	PlayerData    string `json:"PlayerData"`
+}
+
+type UpdateGameSessionInput struct {
+	GameSessionId    string `json:"GameSessionId"`
+	UpdateReason     string `json:"UpdateReason"` // MATCHMAKING_DATA_UPDATED, BACKFILL_FAILED, BACKFILL_TIMED_OUT, BACKFILL_CANCELLED
+	BackfillTicketId string `json:"BackfillTicketId"`
+	MatchmakerData   string `json:"MatchmakerData"` // replaces the game session's when not empty
+}
+
+type TerminateProcessInput struct {
+	ProcessId          string `json:"ProcessId"`
+	TerminationSeconds int    `json:"TerminationSeconds"`
+}
+
+type ProcessState struct {
+	ProcessId     string   `json:"ProcessId"`
+	ComputeId     string   `json:"ComputeId"`
+	Connected     bool     `json:"Connected"`
+	Ready         bool     `json:"Ready"`
+	Ended         bool     `json:"Ended"`
+	Healthy       bool     `json:"Healthy"`
+	Port          int      `json:"Port"`
+	LogPaths      []string `json:"LogPaths"`
+	GameSessionId string   `json:"GameSessionId"`
+}
+
+type GameSessionState struct {
+	GameSessionMessage
+	ProcessId string `json:"ProcessId"`
+	Status    string `json:"Status"`
+}
+
+type FleetState struct {
+	FleetId        string                 `json:"FleetId"`
+	Processes      []ProcessState         `json:"Processes"`
+	GameSessions   []GameSessionState     `json:"GameSessions"`
+	PlayerSessions []PlayerSessionMessage `json:"PlayerSessions"`
+	Events         []Event                `json:"Events"`
+}
+
+func (f *Fleet) ControlHandler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/game-sessions", f.post(func(r *http.Request) (interface{}, int, error) {
+		var in CreateGameSessionInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return f.CreateGameSession(&in)
+	}))
+	mux.HandleFunc("/player-sessions", f.post(func(r *http.Request) (interface{}, int, error) {
+		var in CreatePlayerSessionInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return f.CreatePlayerSession(&in)
+	}))
+	mux.HandleFunc("/update-game-session", f.post(func(r *http.Request) (interface{}, int, error) {
+		var in UpdateGameSessionInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return f.UpdateGameSession(&in)
+	}))
+	mux.HandleFunc("/terminate-process", f.post(func(r *http.Request) (interface{}, int, error) {
+		var in TerminateProcessInput
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		return f.TerminateProcess(&in)
+	}))
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, f.State())
+	})
+
+	return mux
+}
+
+func (f *Fleet) post(handle func(r *http.Request) (interface{}, int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		out, status, err := handle(r)
+		if err != nil {
+			writeJson(w, status, map[string]string{"Error": err.Error()})
+			return
+		}
+		writeJson(w, status, out)
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func (f *Fleet) CreateGameSession(in *CreateGameSessionInput) (interface{}, int, error) {
+	f.mLock.Lock()
+
+	var proc *ServerProcess
+	if in.ProcessId != "" {
+		proc = f.mProcesses[in.ProcessId]
+	} else {
+		for _, p := range f.mProcesses {
+			if p.mConnected && p.mReady && p.mGameSessionId == "" {
+				proc = p
+				break
+			}
+		}
+	}
+	if proc == nil || !proc.mConnected || !proc.mReady || proc.mGameSessionId != "" {
+		f.mLock.Unlock()
+		return nil, http.StatusConflict, fmt.Errorf("no idle server process")
+	}
+
+	if in.MaximumPlayerSessionCount == 0 {
+		in.MaximumPlayerSessionCount = 2
+	}
+
+	gs := &GameSession{
+		mMessage: GameSessionMessage{
+			GameSessionId:             fmt.Sprintf("arn:aws:gamelift:local::gamesession/%s/%s", f.mFleetId, f.newId("gsess")),
+			GameSessionName:           in.Name,
+			FleetId:                   f.mFleetId,
+			MaximumPlayerSessionCount: in.MaximumPlayerSessionCount,
+			Port:                      proc.mPort,
+			IpAddress:                 f.mIpAddress,
+			GameSessionData:           in.GameSessionData,
+			MatchmakerData:            in.MatchmakerData,
+			GameProperties:            in.GameProperties,
+		},
+		mProcessId: proc.mProcessId,
+		mStatus:    GAME_SESSION_ACTIVATING,
+	}
+	f.mGameSessions[gs.mMessage.GameSessionId] = gs
+	proc.mGameSessionId = gs.mMessage.GameSessionId
+	f.record(proc.mProcessId, "CreateGameSession", gs.mMessage.GameSessionId)
+	f.mLock.Unlock()
+
+	if err := f.Send(proc, CreateGameSessionMessage{Action: ACTION_CREATE_GAME_SESSION, GameSessionMessage: gs.mMessage}); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+
+	return map[string]interface{}{
+		"GameSessionId": gs.mMessage.GameSessionId,
+		"ProcessId":     proc.mProcessId,
+		"IpAddress":     f.mIpAddress,
+		"Port":          proc.mPort,
+	}, http.StatusCreated, nil
+}
+
+func (f *Fleet) CreatePlayerSession(in *CreatePlayerSessionInput) (interface{}, int, error) {
+	f.mLock.Lock()
+	defer f.mLock.Unlock()
+
+	gs, ok := f.mGameSessions[in.GameSessionId]
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("no game session %s", in.GameSessionId)
+	}
+	if gs.mStatus == GAME_SESSION_TERMINATED {
+		return nil, http.StatusConflict, fmt.Errorf("game session %s is %s", in.GameSessionId, gs.mStatus)
+	}
+
+	count := 0
+	for _, ps := range f.mPlayerSessions {
+		if ps.GameSessionId == in.GameSessionId && (ps.Status == PLAYER_SESSION_RESERVED || ps.Status == PLAYER_SESSION_ACTIVE) {
+			count++
+		}
+	}
+	if count >= gs.mMessage.MaximumPlayerSessionCount {
+		return nil, http.StatusConflict, fmt.Errorf("game session %s is full", in.GameSessionId)
+	}
+
+	ps := &PlayerSessionMessage{
+		PlayerId:        in.PlayerId,
+		PlayerSessionId: f.newId("psess"),
+		GameSessionId:   in.GameSessionId,
+		FleetId:         f.mFleetId,
+		IpAddress:       f.mIpAddress,
+		Port:            gs.mMessage.Port,
+		PlayerData:      in.PlayerData,
+		Status:          PLAYER_SESSION_RESERVED,
+		CreationTime:    time.Now().UnixMilli(),
+	}
+	f.mPlayerSessions[ps.PlayerSessionId] = ps
+	f.record(gs.mProcessId, "CreatePlayerSession", ps.PlayerSessionId+" "+ps.PlayerId)
+
+	return ps, http.StatusCreated, nil
+}
+
+func (f *Fleet) UpdateGameSession(in *UpdateGameSessionInput) (interface{}, int, error) {
+	f.mLock.Lock()
+
+	gs, ok := f.mGameSessions[in.GameSessionId]
+	if !ok {
+		f.mLock.Unlock()
+		return nil, http.StatusNotFound, fmt.Errorf("no game session %s", in.GameSessionId)
+	}
+	if in.MatchmakerData != "" {
+		gs.mMessage.MatchmakerData = in.MatchmakerData
+	}
+	proc := f.mProcesses[gs.mProcessId]
+	message := UpdateGameSessionMessage{
+		Action:           ACTION_UPDATE_GAME_SESSION,
+		GameSession:      gs.mMessage,
+		UpdateReason:     in.UpdateReason,
+		BackfillTicketId: in.BackfillTicketId,
+	}
+	f.record(gs.mProcessId, "UpdateGameSession", in.UpdateReason)
+	f.mLock.Unlock()
+
+	if err := f.Send(proc, message); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return message, http.StatusOK, nil
+}
+
+func (f *Fleet) TerminateProcess(in *TerminateProcessInput) (interface{}, int, error) {
+	f.mLock.Lock()
+	proc, ok := f.mProcesses[in.ProcessId]
+	if ok {
+		f.record(in.ProcessId, "TerminateProcess", fmt.Sprintf("in %d seconds", in.TerminationSeconds))
+	}
+	f.mLock.Unlock()
+
+	if !ok {
+		return nil, http.StatusNotFound, fmt.Errorf("no server process %s", in.ProcessId)
+	}
+
+	message := TerminateProcessMessage{
+		Action:          ACTION_TERMINATE_PROCESS,
+		TerminationTime: time.Now().Add(time.Duration(in.TerminationSeconds) * time.Second).UnixMilli(),
+	}
+	if err := f.Send(proc, message); err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	return message, http.StatusOK, nil
+}
+
+func (f *Fleet) State() FleetState {
+	f.mLock.Lock()
+	defer f.mLock.Unlock()
+
+	state := FleetState{FleetId: f.mFleetId, Events: append([]Event(nil), f.mEvents...)}
+	for _, p := range f.mProcesses {
+		state.Processes = append(state.Processes, ProcessState{
+			ProcessId:     p.mProcessId,
+			ComputeId:     p.mComputeId,
+			Connected:     p.mConnected,
+			Ready:         p.mReady,
+			Ended:         p.mEnded,
+			Healthy:       p.mHealthy,
+			Port:          p.mPort,
+			LogPaths:      p.mLogPaths,
+			GameSessionId: p.mGameSessionId,
+		})
+	}
+	for _, gs := range f.mGameSessions {
+		state.GameSessions = append(state.GameSessions, GameSessionState{GameSessionMessage: gs.mMessage, ProcessId: gs.mProcessId, Status: gs.mStatus})
+	}
+	for _, ps := range f.mPlayerSessions {
+		state.PlayerSessions = append(state.PlayerSessions, *ps)
+	}
+	return state
+}
@@ -0,0 +1,296 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	GAME_SESSION_ACTIVATING = "ACTIVATING"
+	GAME_SESSION_ACTIVE     = "ACTIVE"
+	GAME_SESSION_TERMINATED = "TERMINATED"
+
+	PLAYER_SESSION_RESERVED  = "RESERVED"
+	PLAYER_SESSION_ACTIVE    = "ACTIVE"
+	PLAYER_SESSION_COMPLETED = "COMPLETED"
+	PLAYER_SESSION_TIMEDOUT  = "TIMEDOUT"
+)
+
+// ServerProcess is one game server process connected with InitSDK
+type ServerProcess struct {
+	mProcessId     string
+	mComputeId     string
+	mConn          *websocket.Conn
+	mWriteLock     sync.Mutex // gorilla/websocket allows one writer at a time
+	mConnected     bool
+	mReady         bool // ProcessReady
+	mEnded         bool // ProcessEnding
+	mPort          int
+	mLogPaths      []string
+	mHealthy       bool
+	mLastHeartbeat time.Time
+	mGameSessionId string
+}
+
+type GameSession struct {
+	mMessage   GameSessionMessage
+	mProcessId string
+	mStatus    string
+}
+
+// Event is kept for test scripts to check what the game server did
+type Event struct {
+	Time      time.Time `json:"Time"`
+	ProcessId string    `json:"ProcessId"`
+	Action    string    `json:"Action"`
+	Detail    string    `json:"Detail,omitempty"`
+}
+
+// Fleet plays the GameLift service for the server processes of one compute
+type Fleet struct {
+	mLock           sync.Mutex
+	mFleetId        string
+	mIpAddress      string
+	mAuthToken      string // required from InitSDK when not empty
+	mProcesses      map[string]*ServerProcess
+	mGameSessions   map[string]*GameSession
+	mPlayerSessions map[string]*PlayerSessionMessage
+	mEvents         []Event
+	mIdCount        int
+	mUpgrader       websocket.Upgrader
+}
+
+func NewFleet(fleetId string, ipAddress string, authToken string) *Fleet {
+	return &Fleet{
+		mFleetId:        fleetId,
+		mIpAddress:      ipAddress,
+		mAuthToken:      authToken,
+		mProcesses:      make(map[string]*ServerProcess),
+		mGameSessions:   make(map[string]*GameSession),
+		mPlayerSessions: make(map[string]*PlayerSessionMessage),
+	}
+}
+
+// must hold mLock
+func (f *Fleet) record(processId string, action string, detail string) {
+	f.mEvents = append(f.mEvents, Event{Time: time.Now(), ProcessId: processId, Action: action, Detail: detail})
+	log.Printf("[%s] %s %s\n", processId, action, detail)
+}
+
+// must hold mLock
+func (f *Fleet) newId(prefix string) string {
+	f.mIdCount++
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().Unix(), f.mIdCount)
+}
+
+// HandleSdk is where InitSDK connects:
+// {WebSocketURL}?pID={ProcessID}&sdkVersion=..&sdkLanguage=..&Authorization={AuthToken}&ComputeId={HostID}&FleetId={FleetID}
+func (f *Fleet) HandleSdk(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	processId := query.Get("pID")
+
+	if processId == "" {
+		http.Error(w, "pID is required", http.StatusBadRequest)
+		return
+	}
+	if f.mAuthToken != "" && query.Get("Authorization") != f.mAuthToken {
+		http.Error(w, "invalid auth token", http.StatusForbidden)
+		return
+	}
+
+	conn, err := f.mUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Print("websocket upgrade failed: ", err)
+		return
+	}
+
+	f.mLock.Lock()
+	proc, ok := f.mProcesses[processId]
+	if !ok {
+		proc = &ServerProcess{mProcessId: processId}
+		f.mProcesses[processId] = proc
+	}
+	proc.mConn = conn
+	proc.mConnected = true
+	proc.mComputeId = query.Get("ComputeId")
+	f.record(processId, "InitSDK", fmt.Sprintf("sdkVersion=%s sdkLanguage=%s ComputeId=%s", query.Get("sdkVersion"), query.Get("sdkLanguage"), proc.mComputeId))
+	f.mLock.Unlock()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(data, &req); err != nil {
+			resp = Response{StatusCode: STATUS_BAD_REQUEST, ErrorMessage: err.Error()}
+		} else {
+			resp = f.HandleRequest(proc, &req)
+		}
+
+		if err := f.Send(proc, resp); err != nil {
+			break
+		}
+	}
+
+	f.mLock.Lock()
+	if proc.mConn == conn {
+		proc.mConnected = false
+	}
+	f.record(processId, "Disconnected", "")
+	f.mLock.Unlock()
+	conn.Close()
+}
+
+func (f *Fleet) Send(proc *ServerProcess, message interface{}) error {
+	proc.mWriteLock.Lock()
+	defer proc.mWriteLock.Unlock()
+
+	if proc.mConn == nil {
+		return errors.New("process " + proc.mProcessId + " isn't connected")
+	}
+	return proc.mConn.WriteJSON(message)
+}
+
+func (f *Fleet) HandleRequest(proc *ServerProcess, req *Request) Response {
+	f.mLock.Lock()
+	defer f.mLock.Unlock()
+
+	resp := Response{Action: req.Action, RequestId: req.RequestId, StatusCode: STATUS_OK}
+	fail := func(status int, format string, args ...interface{}) Response {
+		resp.StatusCode = status
+		resp.ErrorMessage = fmt.Sprintf(format, args...)
+		f.record(proc.mProcessId, req.Action+" failed", resp.ErrorMessage)
+		return resp
+	}
+
+	switch req.Action {
+	case ACTION_ACTIVATE_SERVER_PROCESS:
+		proc.mReady = true
+		proc.mPort = req.Port
+		proc.mLogPaths = req.LogPaths
+		f.record(proc.mProcessId, "ProcessReady", fmt.Sprintf("port=%d logPaths=%v", req.Port, req.LogPaths))
+
+	case ACTION_HEARTBEAT_SERVER_PROCESS:
+		proc.mHealthy = req.HealthStatus
+		proc.mLastHeartbeat = time.Now()
+
+	case ACTION_TERMINATE_SERVER_PROCESS:
+		proc.mReady = false
+		proc.mEnded = true
+		if gs, ok := f.mGameSessions[proc.mGameSessionId]; ok {
+			gs.mStatus = GAME_SESSION_TERMINATED
+			f.TerminatePlayerSessions(gs.mMessage.GameSessionId)
+		}
+		f.record(proc.mProcessId, "ProcessEnding", "")
+
+	case ACTION_ACTIVATE_GAME_SESSION:
+		gs, ok := f.mGameSessions[req.GameSessionId]
+		if !ok || gs.mProcessId != proc.mProcessId {
+			return fail(STATUS_NOT_FOUND, "game session %s isn't placed on this process", req.GameSessionId)
+		}
+		if gs.mStatus != GAME_SESSION_ACTIVATING {
+			return fail(STATUS_BAD_REQUEST, "game session %s is %s", req.GameSessionId, gs.mStatus)
+		}
+		gs.mStatus = GAME_SESSION_ACTIVE
+		f.record(proc.mProcessId, "ActivateGameSession", req.GameSessionId)
+
+	case ACTION_ACCEPT_PLAYER_SESSION:
+		ps, ok := f.mPlayerSessions[req.PlayerSessionId]
+		if !ok || ps.GameSessionId != req.GameSessionId {
+			return fail(STATUS_NOT_FOUND, "player session %s isn't in game session %s", req.PlayerSessionId, req.GameSessionId)
+		}
+		if ps.Status != PLAYER_SESSION_RESERVED {
+			return fail(STATUS_BAD_REQUEST, "player session %s is %s", req.PlayerSessionId, ps.Status)
+		}
+		ps.Status = PLAYER_SESSION_ACTIVE
+		f.record(proc.mProcessId, "AcceptPlayerSession", req.PlayerSessionId)
+
+	case ACTION_REMOVE_PLAYER_SESSION:
+		ps, ok := f.mPlayerSessions[req.PlayerSessionId]
+		if !ok || ps.GameSessionId != req.GameSessionId {
+			return fail(STATUS_NOT_FOUND, "player session %s isn't in game session %s", req.PlayerSessionId, req.GameSessionId)
+		}
+		if ps.Status == PLAYER_SESSION_COMPLETED || ps.Status == PLAYER_SESSION_TIMEDOUT {
+			return fail(STATUS_BAD_REQUEST, "player session %s is %s", req.PlayerSessionId, ps.Status)
+		}
+		ps.Status = PLAYER_SESSION_COMPLETED
+		ps.TerminationTime = time.Now().UnixMilli()
+		f.record(proc.mProcessId, "RemovePlayerSession", req.PlayerSessionId)
+
+	case ACTION_DESCRIBE_PLAYER_SESSIONS:
+		for _, ps := range f.mPlayerSessions {
+			if (req.PlayerSessionId == "" || ps.PlayerSessionId == req.PlayerSessionId) &&
+				(req.GameSessionId == "" || ps.GameSessionId == req.GameSessionId) &&
+				(req.PlayerId == "" || ps.PlayerId == req.PlayerId) &&
+				(req.PlayerSessionStatusFilter == "" || ps.Status == req.PlayerSessionStatusFilter) {
+				resp.PlayerSessions = append(resp.PlayerSessions, *ps)
+			}
+		}
+		if req.PlayerSessionId != "" && len(resp.PlayerSessions) == 0 {
+			return fail(STATUS_NOT_FOUND, "no player session %s", req.PlayerSessionId)
+		}
+
+	case ACTION_UPDATE_PLAYER_SESSION_CP:
+		f.record(proc.mProcessId, "UpdatePlayerSessionCreationPolicy", req.PlayerSessionPolicy)
+
+	case ACTION_START_MATCH_BACKFILL:
+		resp.TicketId = req.TicketId
+		if resp.TicketId == "" {
+			resp.TicketId = f.newId("backfill")
+		}
+		players, _ := json.Marshal(req.Players)
+		f.record(proc.mProcessId, "StartMatchBackfill", fmt.Sprintf("ticket=%s gameSession=%s players=%s", resp.TicketId, req.GameSessionArn, players))
+
+	case ACTION_STOP_MATCH_BACKFILL:
+		f.record(proc.mProcessId, "StopMatchBackfill", "ticket="+req.TicketId)
+
+	default:
+		return fail(STATUS_BAD_REQUEST, "unsupported action %q", req.Action)
+	}
+
+	return resp
+}
+
+// must hold mLock
+func (f *Fleet) TerminatePlayerSessions(gameSessionId string) {
+	for _, ps := range f.mPlayerSessions {
+		if ps.GameSessionId != gameSessionId {
+			continue
+		}
+		switch ps.Status {
+		case PLAYER_SESSION_RESERVED:
+			ps.Status = PLAYER_SESSION_TIMEDOUT
+		case PLAYER_SESSION_ACTIVE:
+			ps.Status = PLAYER_SESSION_COMPLETED
+			ps.TerminationTime = time.Now().UnixMilli()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+// gamelift-local speaks the websocket protocol of the GameLift server SDK 5.x so an unmodified
+// game server can run end to end without a fleet. The SDK finds it through the same environment
+// variables the GameLift agent sets:
+//
+//	GAMELIFT_SDK_WEBSOCKET_URL=ws://127.0.0.1:5200 GAMELIFT_SDK_PROCESS_ID=gomoku-1 \
+//	GAMELIFT_SDK_HOST_ID=local GAMELIFT_SDK_FLEET_ID=fleet-local GAMELIFT_SDK_AUTH_TOKEN=local ./gomoku
+//
+// and drive game and player sessions through the control API (see ControlApi.go).
+
+import (
+	"flag"
+	"log"
+	"net/http"
+)
+
+func main() {
+	sdkAddr := flag.String("sdk-addr", ":5200", "address the game server's InitSDK connects to")
+	controlAddr := flag.String("control-addr", "127.0.0.1:5201", "address of the HTTP control API for test scripts")
+	fleetId := flag.String("fleet-id", "fleet-local", "fleet id reported in game and player sessions")
+	ipAddress := flag.String("ip-address", "127.0.0.1", "ip address players connect to")
+	authToken := flag.String("auth-token", "", "reject InitSDK without this auth token. Any token is accepted when empty")
+	flag.Parse()
+
+	fleet := NewFleet(*fleetId, *ipAddress, *authToken)
+
+	go func() {
+		log.Printf("control API on %s\n", *controlAddr)
+		log.Fatal(http.ListenAndServe(*controlAddr, fleet.ControlHandler()))
+	}()
+
+	log.Printf("waiting for InitSDK on %s\n", *sdkAddr)
+	log.Fatal(http.ListenAndServe(*sdkAddr, http.HandlerFunc(fleet.HandleSdk)))
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright Amazon.com, Inc. or its affiliates. All Rights Reserved.
+ * SPDX-License-Identifier: MIT-0
+ *
+ * Permission is hereby granted, free of charge, to any person obtaining a copy of this
+ * software and associated documentation files (the "Software"), to deal in the Software
+ * without restriction, including without limitation the rights to use, copy, modify,
+ * merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+ * permit persons to whom the Software is furnished to do so.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED,
+ * INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A
+ * PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT
+ * HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION
+ * OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE
+ * SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+ */
+
+package main
+
+// Messages of the websocket protocol between the GameLift server SDK 5.x and the GameLift service.
+// Every message is a JSON object with an "Action". Requests from the SDK carry a "RequestId"
+// which the response echoes along with a "StatusCode".
+
+const (
+	/// SDK to service
+	ACTION_ACTIVATE_SERVER_PROCESS  = "ActivateServerProcess"  // ProcessReady
+	ACTION_HEARTBEAT_SERVER_PROCESS = "HeartbeatServerProcess" // OnHealthCheck result
+	ACTION_TERMINATE_SERVER_PROCESS = "TerminateServerProcess" // ProcessEnding
+	ACTION_ACTIVATE_GAME_SESSION    = "ActivateGameSession"
+	ACTION_ACCEPT_PLAYER_SESSION    = "AcceptPlayerSession"
+	ACTION_REMOVE_PLAYER_SESSION    = "RemovePlayerSession"
+	ACTION_DESCRIBE_PLAYER_SESSIONS = "DescribePlayerSessions"
+	ACTION_UPDATE_PLAYER_SESSION_CP = "UpdatePlayerSessionCreationPolicy"
+	ACTION_START_MATCH_BACKFILL     = "StartMatchBackfill"
+	ACTION_STOP_MATCH_BACKFILL      = "StopMatchBackfill"
+
+	/// service to SDK
+	ACTION_CREATE_GAME_SESSION = "CreateGameSession" // OnStartGameSession
+	ACTION_UPDATE_GAME_SESSION = "UpdateGameSession" // OnUpdateGameSession
+	ACTION_TERMINATE_PROCESS   = "TerminateProcess"  // OnProcessTerminate
+)
+
+const (
+	STATUS_OK          = 200
+	STATUS_BAD_REQUEST = 400
+	STATUS_NOT_FOUND   = 404
+)
+
+// Request has the fields of all SDK requests. Unused ones stay empty.
+type Request struct {
+	Action    string `json:"Action"`
+	RequestId string `json:"RequestId"`
+
+	/// ActivateServerProcess
+	SdkVersion  string   `json:"SdkVersion,omitempty"`
+	SdkLanguage string   `json:"SdkLanguage,omitempty"`
+	Port        int      `json:"Port,omitempty"`
+	LogPaths    []string `json:"LogPaths,omitempty"`
+
+	/// HeartbeatServerProcess
+	HealthStatus bool `json:"HealthStatus,omitempty"`
+
+	/// game and player sessions
+	GameSessionId             string `json:"GameSessionId,omitempty"`
+	PlayerSessionId           string `json:"PlayerSessionId,omitempty"`
+	PlayerId                  string `json:"PlayerId,omitempty"`
+	PlayerSessionStatusFilter string `json:"PlayerSessionStatusFilter,omitempty"`
+	PlayerSessionPolicy       string `json:"PlayerSessionPolicy,omitempty"`
+	NextToken                 string `json:"NextToken,omitempty"`
+	Limit                     int    `json:"Limit,omitempty"`
+
+	/// StartMatchBackfill, StopMatchBackfill
+	TicketId                    string                   `json:"TicketId,omitempty"`
+	GameSessionArn              string                   `json:"GameSessionArn,omitempty"`
+	MatchmakingConfigurationArn string                   `json:"MatchmakingConfigurationArn,omitempty"`
+	Players                     []map[string]interface{} `json:"Players,omitempty"` // only recorded
+}
+
+type Response struct {
+	Action       string `json:"Action"`
+	RequestId    string `json:"RequestId"`
+	StatusCode   int    `json:"StatusCode"`
+	ErrorMessage string `json:"ErrorMessage,omitempty"`
+
+	/// DescribePlayerSessions
+	PlayerSessions []PlayerSessionMessage `json:"PlayerSessions,omitempty"`
+	NextToken      string                 `json:"NextToken,omitempty"`
+
+	/// StartMatchBackfill
+	TicketId string `json:"TicketId,omitempty"`
+}
+
+type PlayerSessionMessage struct {
+	PlayerId        string `json:"PlayerId"`
+	PlayerSessionId string `json:"PlayerSessionId"`
+	GameSessionId   string `json:"GameSessionId"`
+	FleetId         string `json:"FleetId"`
+	IpAddress       string `json:"IpAddress"`
+	DnsName         string `json:"DnsName"`
+	Port            int    `json:"Port"`
+	PlayerData      string `json:"PlayerData"`
+	Status          string `json:"Status"`
+	CreationTime    int64  `json:"CreationTime"`
+	TerminationTime int64  `json:"TerminationTime"`
+}
+
+type GameSessionMessage struct {
+	GameSessionId             string            `json:"GameSessionId"`
+	GameSessionName           string            `json:"GameSessionName"`
+	FleetId                   string            `json:"FleetId"`
+	MaximumPlayerSessionCount int               `json:"MaximumPlayerSessionCount"`
+	Port                      int               `json:"Port"`
+	IpAddress                 string            `json:"IpAddress"`
+	DnsName                   string            `json:"DnsName"`
+	GameSessionData           string            `json:"GameSessionData"`
+	MatchmakerData            string            `json:"MatchmakerData"`
+	GameProperties            map[string]string `json:"GameProperties"`
+}
+
+type CreateGameSessionMessage struct {
+	Action string `json:"Action"`
+	GameSessionMessage
+}
+
+type UpdateGameSessionMessage struct {
+	Action           string             `json:"Action"`
+	GameSession      GameSessionMessage `json:"GameSession"`
+	UpdateReason     string             `json:"UpdateReason"`
+	BackfillTicketId string             `json:"BackfillTicketId"`
+}
+
+type TerminateProcessMessage struct {
+	Action          string `json:"Action"`
+	TerminationTime int64  `json:"TerminationTime"` // epoch milliseconds
+}
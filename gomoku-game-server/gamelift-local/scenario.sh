@@ -0,0 +1,137 @@
+#!/bin/bash
+
+# End to end scenario of the game server against gamelift-local, for CI:
+# InitSDK and ProcessReady, CreateGameSession, two player sessions, a game to five in a row, ProcessEnding.
+# Fails on the first step that doesn't happen. Needs the GameLift Go server SDK in ../GameLift-Go-ServerSDK-5.1.0,
+# go, curl, jq and python3.
+#
+#   ./gamelift-local/scenario.sh
+
+set -euo pipefail
+cd "$(dirname "$0")/.."
+
+SDK_ADDR=127.0.0.1:${SDK_PORT:-5200}
+CONTROL=http://127.0.0.1:${CONTROL_PORT:-5201}
+GAME_PORT=${GAME_PORT:-4999}
+PROCESS_ID=scenario-$$
+
+WORKDIR=$(mktemp -d)
+trap 'kill $(jobs -p) 2>/dev/null; rm -rf "$WORKDIR" /tmp/$GAME_PORT.state' EXIT
+
+fail() {
+  echo "FAIL: $*"
+  echo "--- gamelift-local"
+  cat "$WORKDIR/gamelift-local.log"
+  echo "--- game server"
+  cat "$WORKDIR"/logs/*.log
+  exit 1
+}
+
+# wait_for {seconds} {jq filter on GET /state}
+wait_for() {
+  for _ in $(seq $(($1 * 10))); do
+    if curl -sf "$CONTROL/state" | jq -e "$2" > /dev/null; then
+      return 0
+    fi
+    sleep 0.1
+  done
+  return 1
+}
+
+go build -o "$WORKDIR/gomoku-in-go" .
+go build -o "$WORKDIR/gamelift-local" ./gamelift-local
+
+"$WORKDIR/gamelift-local" -sdk-addr "$SDK_ADDR" -control-addr "${CONTROL#http://}" > "$WORKDIR/gamelift-local.log" 2>&1 &
+sleep 1
+
+(cd "$WORKDIR" && GAMELIFT_SDK_WEBSOCKET_URL=ws://$SDK_ADDR GAMELIFT_SDK_PROCESS_ID=$PROCESS_ID GAMELIFT_SDK_HOST_ID=local \
+  GAMELIFT_SDK_FLEET_ID=fleet-local GAMELIFT_SDK_AUTH_TOKEN=local ./gomoku-in-go --port "$GAME_PORT" > gomoku-in-go.out 2>&1) &
+
+wait_for 10 ".Processes[] | select(.ProcessId == \"$PROCESS_ID\" and .Ready)" || fail "no ProcessReady"
+echo "process $PROCESS_ID is ready"
+
+GAME_SESSION_ID=$(curl -sf -X POST -d "{\"ProcessId\" : \"$PROCESS_ID\"}" "$CONTROL/game-sessions" | jq -r .GameSessionId)
+wait_for 5 ".GameSessions[] | select(.GameSessionId == \"$GAME_SESSION_ID\" and .Status == \"ACTIVE\")" || fail "game session not activated"
+echo "game session $GAME_SESSION_ID is active"
+
+PLAYER_SESSIONS=()
+for player in alice bob; do
+  PLAYER_SESSIONS+=($(curl -sf -X POST -d "{\"GameSessionId\" : \"$GAME_SESSION_ID\", \"PlayerId\" : \"$player\"}" "$CONTROL/player-sessions" | jq -r .PlayerSessionId))
+done
+echo "player sessions ${PLAYER_SESSIONS[*]}"
+
+# Both players connect, black makes five in a row on the first line while white plays the second, and both leave
+python3 - "$GAME_PORT" "${PLAYER_SESSIONS[@]}" <<'EOF' || fail "game"
+import socket, struct, sys, time
+
+PKT_CS_START, PKT_SC_START, PKT_CS_PUT_STONE, PKT_CS_EXIT, PKT_SC_GAME_RESULT = 1, 2, 21, 31, 91
+STONE_BLACK = 2
+MAX_SESSION_LEN, MAX_STRING_LEN = 128, 64
+
+port, sessions = int(sys.argv[1]), sys.argv[2:]
+
+def recv_packet(s):
+    header = b''
+    while len(header) < 4:
+        header += s.recv(4 - len(header))
+    size, ptype = struct.unpack('<HH', header)
+    body = b''
+    while len(body) < size - 4:
+        body += s.recv(size - 4 - len(body))
+    return ptype, body
+
+def recv_until(s, ptype):
+    while True:
+        t, body = recv_packet(s)
+        if t == ptype:
+            return body
+
+players = {}
+for psess in sessions:
+    s = socket.create_connection(('127.0.0.1', port), timeout=10)
+    s.send(struct.pack('<HH128s', 4 + MAX_SESSION_LEN, PKT_CS_START, psess.encode()))
+    players[psess] = s
+    time.sleep(0.2)
+
+black = white = None
+for psess, s in players.items():
+    body = recv_until(s, PKT_SC_START)
+    if body[:MAX_SESSION_LEN].rstrip(b'\0').decode() == psess:
+        black = s
+    else:
+        white = s
+if black is None or white is None:
+    sys.exit('no black player in PKT_SC_START')
+
+for x in range(5):
+    black.send(struct.pack('<HHii', 12, PKT_CS_PUT_STONE, x, 0))
+    time.sleep(0.1)
+    if x < 4:
+        white.send(struct.pack('<HHii', 12, PKT_CS_PUT_STONE, x, 1))
+        time.sleep(0.1)
+
+for s in (black, white):
+    winner = recv_until(s, PKT_SC_GAME_RESULT)[0]
+    if winner != STONE_BLACK:
+        sys.exit('winner %d, want black' % winner)
+
+for psess, s in players.items():
+    s.send(struct.pack('<HH128s', 4 + MAX_SESSION_LEN, PKT_CS_EXIT, psess.encode()))
+time.sleep(0.5)
+EOF
+echo "black won"
+
+wait_for 15 ".Processes[] | select(.ProcessId == \"$PROCESS_ID\" and .Ended)" || fail "no ProcessEnding"
+
+# The calls the game server made, in order
+EXPECTED=(InitSDK ProcessReady CreateGameSession ActivateGameSession AcceptPlayerSession AcceptPlayerSession RemovePlayerSession RemovePlayerSession ProcessEnding)
+ACTIONS=($(curl -sf "$CONTROL/state" | jq -r ".Events[] | select(.ProcessId == \"$PROCESS_ID\") | .Action"))
+i=0
+for action in "${ACTIONS[@]}"; do
+  if [ $i -lt ${#EXPECTED[@]} ] && [ "$action" == "${EXPECTED[$i]}" ]; then
+    i=$((i + 1))
+  fi
+done
+[ $i -eq ${#EXPECTED[@]} ] || fail "calls ${ACTIONS[*]}, missing ${EXPECTED[$i]}"
+
+echo "PASS"
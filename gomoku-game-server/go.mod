@@ -8,8 +8,10 @@ require (
 	aws/amazon-gamelift-go-sdk v0.0.0-00010101000000-000000000000
 	github.com/aws/aws-sdk-go-v2 v1.18.0
 	github.com/aws/aws-sdk-go-v2/config v1.18.22
+	github.com/aws/aws-sdk-go-v2/service/gamelift v1.18.0
 	github.com/aws/aws-sdk-go-v2/service/sqs v1.20.9
 	github.com/google/uuid v1.5.0
+	github.com/gorilla/websocket v1.5.1
 )
 
 require (
@@ -23,7 +25,6 @@ require (
 	github.com/aws/aws-sdk-go-v2/service/ssooidc v1.14.9 // indirect
 	github.com/aws/aws-sdk-go-v2/service/sts v1.18.10 // indirect
 	github.com/aws/smithy-go v1.13.5 // indirect
-	github.com/gorilla/websocket v1.5.1 // indirect
 	github.com/sethvargo/go-retry v0.2.4 // indirect
 	golang.org/x/net v0.20.0 // indirect
 )